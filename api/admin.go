@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/logging"
+)
+
+type cacheInfo struct {
+	Generation    uint64  `json:"generation"`
+	Entries       int     `json:"entries"`
+	Fresh         bool    `json:"fresh"`
+	AgeSeconds    float64 `json:"age_seconds"`
+	TTLSeconds    float64 `json:"ttl_seconds"` // 0 if the cache never expires
+	LastRefreshed string  `json:"last_refreshed,omitempty"`
+	LastError     string  `json:"last_error,omitempty"`
+}
+
+// adminCache shows metadata about the character cache on GET and purges it on
+// DELETE.
+func (a *API) adminCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodDelete:
+		a.core.PurgeCache()
+		slog.InfoContext(r.Context(), "Purged cache")
+	default:
+		w.Header().Set("allow", "GET, HEAD, DELETE")
+		httpError(w, r, http.StatusMethodNotAllowed, "error_method_not_allowed")
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, a.cacheInfo())
+}
+
+func (a *API) cacheInfo() cacheInfo {
+	status := a.core.CacheStatus()
+	now := time.Now()
+
+	info := cacheInfo{
+		Generation: status.Generation,
+		Entries:    status.Entries,
+		Fresh:      status.Fresh(now),
+		AgeSeconds: status.Age(now).Seconds(),
+		TTLSeconds: status.TTL.Seconds(),
+		LastError:  status.LastError,
+	}
+
+	if !status.Updated.IsZero() {
+		info.LastRefreshed = status.Updated.UTC().Format(time.RFC3339)
+	}
+
+	return info
+}
+
+// adminRefresh refreshes the character cache on POST. The refresh is done
+// before responding, or in the background with ?async=true, in which case the
+// response points to the refresh job to poll for its status.
+func (a *API) adminRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("allow", "POST")
+		httpError(w, r, http.StatusMethodNotAllowed, "error_method_not_allowed")
+		return
+	}
+
+	if async, _ := strconv.ParseBool(r.URL.Query().Get("async")); async {
+		job := a.refreshJobs.start(r.Context(), a.core.RefreshCache)
+
+		w.Header().Set("location", "/admin/cache/refresh/"+job.ID)
+		writeAdminJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	if err := a.core.RefreshCache(r.Context()); err != nil {
+		slog.ErrorContext(r.Context(), "Error refreshing cache", "error", err)
+		writeAdminJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, a.cacheInfo())
+}
+
+// adminRefreshJob shows the status of the refresh job with the ID given by the
+// last path segment.
+func (a *API) adminRefreshJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("allow", "GET, HEAD")
+		httpError(w, r, http.StatusMethodNotAllowed, "error_method_not_allowed")
+		return
+	}
+
+	job, ok := a.refreshJobs.get(strings.TrimPrefix(r.URL.Path, "/admin/cache/refresh/"))
+	if !ok {
+		httpError(w, r, http.StatusNotFound, "error_no_such_job")
+		return
+	}
+
+	writeAdminJSON(w, http.StatusOK, job)
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("cache-control", "no-store")
+	w.Header().Set("content-type", "application/json")
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(v)
+}
+
+// Refresh job states.
+const (
+	jobRunning   = "running"
+	jobSucceeded = "succeeded"
+	jobFailed    = "failed"
+)
+
+type refreshJob struct {
+	ID       string `json:"id"`
+	State    string `json:"state"`
+	Started  string `json:"started"`
+	Finished string `json:"finished,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// maxRefreshJobs is the number of finished refresh jobs kept for polling.
+const maxRefreshJobs = 100
+
+// refreshJobs runs cache refreshes in the background, one at a time, and
+// keeps track of their outcome.
+type refreshJobs struct {
+	ctx     context.Context // cancels running jobs
+	wg      sync.WaitGroup
+	mu      sync.Mutex
+	jobs    map[string]*refreshJob
+	order   []string // IDs of jobs, oldest first
+	running *refreshJob
+}
+
+// start starts refresh in the background and returns its job. The refresh
+// outlives the request with context ctx, but is logged and traced as part of
+// it, and is cancelled with js.ctx. If a refresh is already running, its job
+// is returned instead.
+func (js *refreshJobs) start(ctx context.Context, refresh func(ctx context.Context) error) refreshJob {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	if js.running != nil {
+		return *js.running
+	}
+
+	if js.jobs == nil {
+		js.jobs = make(map[string]*refreshJob)
+	}
+
+	job := &refreshJob{
+		ID:      logging.NewRequestID(),
+		State:   jobRunning,
+		Started: time.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	js.jobs[job.ID] = job
+	js.order = append(js.order, job.ID)
+	js.running = job
+
+	if len(js.order) > maxRefreshJobs {
+		delete(js.jobs, js.order[0])
+		js.order = js.order[1:]
+	}
+
+	ctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(js.ctx, cancel)
+
+	js.wg.Add(1)
+
+	go func() {
+		defer js.wg.Done()
+		defer stop()
+		defer cancel()
+
+		err := refresh(ctx)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "Error refreshing cache", "job", job.ID, "error", err)
+		} else {
+			slog.InfoContext(ctx, "Refreshed cache", "job", job.ID)
+		}
+
+		js.mu.Lock()
+		defer js.mu.Unlock()
+
+		job.Finished = time.Now().UTC().Format(time.RFC3339Nano)
+
+		if err != nil {
+			job.State = jobFailed
+			job.Error = err.Error()
+		} else {
+			job.State = jobSucceeded
+		}
+
+		js.running = nil
+	}()
+
+	return *job
+}
+
+// get returns the job with the given ID.
+func (js *refreshJobs) get(id string) (refreshJob, bool) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	job, ok := js.jobs[id]
+	if !ok {
+		return refreshJob{}, false
+	}
+
+	return *job, true
+}
+
+// wait waits for the running job, if any, to finish.
+func (js *refreshJobs) wait() {
+	js.wg.Wait()
+}
@@ -0,0 +1,312 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Admin(t *testing.T) {
+	authn := authenticatorFunc(func(credential string) (auth.Principal, error) {
+		switch credential {
+		case "admin":
+			return auth.Principal{ID: "admin", Scopes: []string{auth.ScopeAdmin}}, nil
+		case "reader":
+			return auth.Principal{ID: "reader", Scopes: []string{auth.ScopeRead}}, nil
+		default:
+			return auth.Principal{}, auth.ErrUnauthenticated
+		}
+	})
+
+	var (
+		mu         sync.Mutex
+		status     = starwars.CacheStatus{Generation: 3, Entries: 82, Updated: time.Now().Add(-time.Minute), TTL: time.Hour}
+		refreshErr error
+		refreshed  = make(chan struct{}, 1)
+	)
+
+	c := &mock.Core{
+		CacheStatusFunc: func() starwars.CacheStatus {
+			mu.Lock()
+			defer mu.Unlock()
+			return status
+		},
+		PurgeCacheFunc: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			status.Entries = 0
+			status.Updated = time.Time{}
+		},
+		RefreshCacheFunc: func(ctx context.Context) error {
+			<-refreshed
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if refreshErr != nil {
+				return refreshErr
+			}
+
+			status.Generation++
+			status.Entries = 82
+			status.Updated = time.Now()
+
+			return nil
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(c, WithAuth(authn)).Register(mux)
+
+	request := func(method, path, credential string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("x-api-key", credential)
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	decode := func(t *testing.T, w *httptest.ResponseRecorder, v any) {
+		t.Helper()
+
+		if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+			t.Fatalf("error decoding response: %v", err)
+		}
+	}
+
+	t.Run("Status", func(t *testing.T) {
+		w := request(http.MethodGet, "/admin/cache", "admin")
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		var info cacheInfo
+		decode(t, w, &info)
+
+		if got, want := info.Generation, uint64(3); got != want {
+			t.Errorf("got generation %d, want %d", got, want)
+		}
+
+		if got, want := info.Entries, 82; got != want {
+			t.Errorf("got %d entries, want %d", got, want)
+		}
+
+		if !info.Fresh {
+			t.Error("cache not fresh")
+		}
+
+		if got, want := info.TTLSeconds, 3600.0; got != want {
+			t.Errorf("got TTL %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Purge", func(t *testing.T) {
+		w := request(http.MethodDelete, "/admin/cache", "admin")
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		var info cacheInfo
+		decode(t, w, &info)
+
+		if info.Entries != 0 || info.Fresh || info.LastRefreshed != "" {
+			t.Errorf("got %+v, want purged cache", info)
+		}
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		refreshed <- struct{}{}
+
+		w := request(http.MethodPost, "/admin/cache/refresh", "admin")
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		var info cacheInfo
+		decode(t, w, &info)
+
+		if got, want := info.Generation, uint64(4); got != want {
+			t.Errorf("got generation %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Refresh error", func(t *testing.T) {
+		mu.Lock()
+		refreshErr = errors.New("foo error")
+		mu.Unlock()
+
+		defer func() {
+			mu.Lock()
+			refreshErr = nil
+			mu.Unlock()
+		}()
+
+		refreshed <- struct{}{}
+
+		w := request(http.MethodPost, "/admin/cache/refresh", "admin")
+
+		if got, want := w.Code, http.StatusBadGateway; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Async refresh", func(t *testing.T) {
+		w := request(http.MethodPost, "/admin/cache/refresh?async=true", "admin")
+
+		if got, want := w.Code, http.StatusAccepted; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		var job refreshJob
+		decode(t, w, &job)
+
+		if got, want := job.State, jobRunning; got != want {
+			t.Errorf("got state %q, want %q", got, want)
+		}
+
+		if got, want := w.Header().Get("location"), "/admin/cache/refresh/"+job.ID; got != want {
+			t.Errorf("got location %q, want %q", got, want)
+		}
+
+		w = request(http.MethodPost, "/admin/cache/refresh?async=true", "admin")
+
+		var again refreshJob
+		decode(t, w, &again)
+
+		if got, want := again.ID, job.ID; got != want {
+			t.Errorf("got job %q while running, want %q", got, want)
+		}
+
+		refreshed <- struct{}{}
+
+		deadline := time.Now().Add(5 * time.Second)
+
+		for job.State == jobRunning {
+			if time.Now().After(deadline) {
+				t.Fatal("refresh job did not finish")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+
+			w := request(http.MethodGet, "/admin/cache/refresh/"+job.ID, "admin")
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			decode(t, w, &job)
+		}
+
+		if got, want := job.State, jobSucceeded; got != want {
+			t.Errorf("got state %q, want %q", got, want)
+		}
+
+		if job.Finished == "" {
+			t.Error("finished job has no finish time")
+		}
+	})
+
+	for _, tc := range []struct {
+		desc       string
+		method     string
+		path       string
+		credential string
+		wantCode   int
+	}{
+		{"Unknown job", http.MethodGet, "/admin/cache/refresh/foo", "admin", http.StatusNotFound},
+		{"Method not allowed", http.MethodGet, "/admin/cache/refresh", "admin", http.StatusMethodNotAllowed},
+		{"Read scope", http.MethodDelete, "/admin/cache", "reader", http.StatusForbidden},
+		{"Anonymous", http.MethodGet, "/admin/cache", "", http.StatusUnauthorized},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if got, want := request(tc.method, tc.path, tc.credential).Code, tc.wantCode; got != want {
+				t.Errorf("got HTTP %d, want %d", got, want)
+			}
+		})
+	}
+
+	t.Run("Without auth", func(t *testing.T) {
+		mux := http.NewServeMux()
+		New(&mock.Core{
+			TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) { return nil, nil },
+			TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) { return nil, nil },
+			CacheStatusFunc:      func() starwars.CacheStatus { return starwars.CacheStatus{} },
+			PurgeCacheFunc: func() {
+				t.Error("cache purged without auth")
+			},
+		}).Register(mux)
+
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/admin/cache", nil))
+	})
+}
+
+func TestAPI_Wait(t *testing.T) {
+	authn := authenticatorFunc(func(credential string) (auth.Principal, error) {
+		return auth.Principal{ID: "admin", Scopes: []string{auth.ScopeAdmin}}, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	started := make(chan struct{})
+
+	var refreshErr error
+
+	a := New(
+		&mock.Core{
+			RefreshCacheFunc: func(ctx context.Context) error {
+				close(started)
+				<-ctx.Done()
+				refreshErr = ctx.Err()
+				return refreshErr
+			},
+		},
+		WithAuth(authn),
+		WithContext(ctx),
+	)
+
+	mux := http.NewServeMux()
+	a.Register(mux)
+
+	r := httptest.NewRequest(http.MethodPost, "/admin/cache/refresh?async=true", nil)
+	r.Header.Set("x-api-key", "admin")
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+
+	if got, want := w.Code, http.StatusAccepted; got != want {
+		t.Fatalf("got HTTP %d, want %d", got, want)
+	}
+
+	<-started
+
+	cancel()
+	a.Wait()
+
+	if got, want := refreshErr, context.Canceled; got != want {
+		t.Errorf("got refresh error %v, want %v", got, want)
+	}
+
+	var job refreshJob
+	if err := json.NewDecoder(w.Body).Decode(&job); err != nil {
+		t.Fatalf("error decoding body: %v", err)
+	}
+
+	job, _ = a.refreshJobs.get(job.ID)
+
+	if got, want := job.State, jobFailed; got != want {
+		t.Errorf("got state %q, want %q", got, want)
+	}
+}
@@ -1,112 +1,133 @@
 package api
 
 import (
-	"bytes"
-	_ "embed"
-	"encoding/json"
-	"log"
+	"context"
 	"net/http"
-	"text/template"
+	"time"
 
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/metrics"
 	"github.com/jsageryd/starwars-coding-test/starwars"
 )
 
-//go:embed index.tmpl
-var index string
-
-var tmpl *template.Template
+type API struct {
+	ctx            context.Context
+	core           starwars.Core
+	readinessGate  bool
+	upstreamBudget time.Duration
+	registry       *metrics.Registry
+	metrics        *apiMetrics
+	rateLimits     map[string]RateLimit
+	defaultLimiter *limiter // shared by the routes limited by "*"
+	authn          Authenticator
+	authFailures   *limiter
+	refreshJobs    refreshJobs
+	cors           *CORS
+	compression    *compression
+	legacySunset   time.Time
+	routes         []string // registered routes
+}
 
-func init() {
-	var err error
+type Option func(*API)
 
-	tmpl, err = template.New("index").Parse(index)
-	if err != nil {
-		log.Fatalf("Error parsing template: %v", err)
+// WithReadinessGate makes the UI and ranking endpoints respond with 503
+// Service Unavailable until the character cache has been populated.
+func WithReadinessGate() Option {
+	return func(a *API) {
+		a.readinessGate = true
 	}
 }
 
-type API struct {
-	core starwars.Core
+// WithUpstreamBudget sets how long the readiness check waits for SWAPI to
+// respond before considering it unreachable. The default is 2 seconds.
+func WithUpstreamBudget(budget time.Duration) Option {
+	return func(a *API) {
+		a.upstreamBudget = budget
+	}
 }
 
-func New(core starwars.Core) *API {
-	return &API{
-		core: core,
+// WithMetrics registers request metrics in reg and serves them at /metrics.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(a *API) {
+		a.registry = reg
 	}
 }
 
-func (a *API) Register(mux *http.ServeMux) {
-	mux.HandleFunc("/", a.ui)
-	mux.HandleFunc("/top-fat-characters", a.topFatCharacters)
-	mux.HandleFunc("/top-old-characters", a.topOldCharacters)
+// WithContext sets the context background work is cancelled with, such as cache
+// refreshes requested to run asynchronously. It is typically cancelled when the
+// server shuts down, after which Wait waits for the work to finish. The default
+// is context.Background().
+func WithContext(ctx context.Context) Option {
+	return func(a *API) {
+		a.ctx = ctx
+	}
 }
 
-func (a *API) ui(w http.ResponseWriter, r *http.Request) {
-	fattestCharacters, err := a.core.TopFatCharacters()
-	if err != nil {
-		http.Error(w, "unknown error", http.StatusInternalServerError)
-		log.Println(err)
-		return
+func New(core starwars.Core, opts ...Option) *API {
+	a := &API{
+		ctx:            context.Background(),
+		core:           core,
+		upstreamBudget: 2 * time.Second,
 	}
 
-	oldestCharacters, err := a.core.TopOldCharacters()
-	if err != nil {
-		http.Error(w, "unknown error", http.StatusInternalServerError)
-		log.Println(err)
-		return
+	for _, opt := range opts {
+		opt(a)
 	}
 
-	data := struct {
-		FattestCharacters []starwars.Character
-		OldestCharacters  []starwars.Character
-	}{
-		FattestCharacters: fattestCharacters,
-		OldestCharacters:  oldestCharacters,
+	if a.registry != nil {
+		a.metrics = newAPIMetrics(a.registry)
+	} else {
+		a.metrics = newAPIMetrics(metrics.NewRegistry())
 	}
 
-	var buf bytes.Buffer
+	a.refreshJobs.ctx = a.ctx
 
-	if err := tmpl.Execute(&buf, data); err != nil {
-		http.Error(w, "Error rendering page", http.StatusInternalServerError)
-		log.Printf("Error rendering page: %v", err)
-		return
-	}
+	return a
+}
 
-	buf.WriteTo(w)
+// Wait waits for background work to finish. It is called after the context
+// given with WithContext has been cancelled, so that the work stops promptly.
+func (a *API) Wait() {
+	a.refreshJobs.wait()
 }
 
-func (a *API) topFatCharacters(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-		return
+func (a *API) Register(mux *http.ServeMux) {
+	a.handle(mux, "/", a.authorized(auth.ScopeRead, a.gated(a.conditional(a.ui))))
+	a.handle(mux, "/search", a.authorized(auth.ScopeRead, a.gated(a.conditional(a.search))))
+	a.handle(mux, "/characters/", a.authorized(auth.ScopeRead, a.gated(a.profile)))
+	for _, kind := range starwars.Kinds {
+		a.handle(mux, "/"+kind+"/", a.authorized(auth.ScopeRead, a.gated(a.entity(kind))))
 	}
-
-	characters, err := a.core.TopFatCharacters()
-	if err != nil {
-		http.Error(w, "unknown error", http.StatusInternalServerError)
-		log.Println(err)
-		return
+	for _, c := range characterCharts {
+		a.handle(mux, "/charts/"+c.name+".svg", a.authorized(auth.ScopeRead, a.gated(a.conditional(a.chart(c)))))
 	}
+	a.registerVersions(mux)
+	a.handle(mux, "/static/", a.static)
+	a.handle(mux, "/healthz", a.healthz)
+	a.handle(mux, "/readyz", a.readyz)
 
-	w.Header().Set("content-type", "application/json")
-
-	json.NewEncoder(w).Encode(&characters)
-}
-
-func (a *API) topOldCharacters(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
-		return
+	if a.registry != nil {
+		a.handle(mux, "/metrics", a.registry.ServeHTTP)
 	}
 
-	characters, err := a.core.TopOldCharacters()
-	if err != nil {
-		http.Error(w, "unknown error", http.StatusInternalServerError)
-		log.Println(err)
-		return
+	// Admin endpoints are only served when clients are authenticated, as
+	// they would otherwise be open to anyone.
+	if a.authn != nil {
+		a.handle(mux, "/admin/cache", a.authorized(auth.ScopeAdmin, a.adminCache))
+		a.handle(mux, "/admin/cache/refresh", a.authorized(auth.ScopeAdmin, a.adminRefresh))
+		a.handle(mux, "/admin/cache/refresh/", a.authorized(auth.ScopeAdmin, a.adminRefreshJob))
 	}
+}
 
-	w.Header().Set("content-type", "application/json")
+// Routes returns the routes of an API with every endpoint enabled, which are
+// the routes that rate limits may be given for, besides "*".
+func Routes() []string {
+	a := New(nil, WithAuth(&auth.Authenticator{}), WithMetrics(metrics.NewRegistry()))
+	a.Register(http.NewServeMux())
+	return a.routes
+}
 
-	json.NewEncoder(w).Encode(&characters)
+func (a *API) handle(mux *http.ServeMux, route string, h http.HandlerFunc) {
+	a.routes = append(a.routes, route)
+	mux.HandleFunc(route, withRequestID(a.traced(route, a.instrumented(route, a.withCORS(a.compressed(a.authenticated(a.rateLimited(route, h))))))))
 }
@@ -1,10 +1,13 @@
 package api
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/jsageryd/starwars-coding-test/mock"
 	"github.com/jsageryd/starwars-coding-test/starwars"
@@ -14,7 +17,7 @@ func TestAPI_TopFatCharacters(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		a := New(
 			&mock.Core{
-				TopFatCharactersFunc: func() ([]starwars.Character, error) {
+				TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
 					return []starwars.Character{
 						{Name: "R2-D2", Height: 96, Mass: 32},
 						{Name: "C-3PO", Height: 167, Mass: 75},
@@ -27,7 +30,7 @@ func TestAPI_TopFatCharacters(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
 
-		a.topFatCharacters(w, r)
+		a.characters(v1, ranking(starwars.Core.TopFatCharacters))(w, r)
 
 		if got, want := w.Code, http.StatusOK; got != want {
 			t.Errorf("got HTTP %d, want %d", got, want)
@@ -43,7 +46,7 @@ func TestAPI_TopFatCharacters(t *testing.T) {
 	t.Run("Error from core", func(t *testing.T) {
 		a := New(
 			&mock.Core{
-				TopFatCharactersFunc: func() ([]starwars.Character, error) {
+				TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
 					return nil, errors.New("foo error")
 				},
 			},
@@ -52,7 +55,7 @@ func TestAPI_TopFatCharacters(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
 
-		a.topFatCharacters(w, r)
+		a.characters(v1, ranking(starwars.Core.TopFatCharacters))(w, r)
 
 		if got, want := w.Code, http.StatusInternalServerError; got != want {
 			t.Errorf("got HTTP %d, want %d", got, want)
@@ -65,11 +68,54 @@ func TestAPI_TopFatCharacters(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodPut, "/", nil)
 
-		a.topFatCharacters(w, r)
+		a.characters(v1, ranking(starwars.Core.TopFatCharacters))(w, r)
 
 		if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
 			t.Errorf("got HTTP %d, want %d", got, want)
 		}
+
+		if got, want := w.Header().Get("allow"), "GET, HEAD"; got != want {
+			t.Errorf("got allow %q, want %q", got, want)
+		}
+	})
+
+	t.Run("HEAD", func(t *testing.T) {
+		mux := http.NewServeMux()
+
+		New(
+			&mock.Core{
+				TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+					return []starwars.Character{{Name: "R2-D2", Height: 96, Mass: 32}}, nil
+				},
+				CacheStatusFunc: func() starwars.CacheStatus {
+					return starwars.CacheStatus{Generation: 1, Updated: time.Now()}
+				},
+			},
+		).Register(mux)
+
+		head := func(etag string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodHead, "/v1/top-fat-characters", nil)
+			if etag != "" {
+				r.Header.Set("if-none-match", etag)
+			}
+			mux.ServeHTTP(w, r)
+			return w
+		}
+
+		w := head("")
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := head(w.Header().Get("etag")).Code, http.StatusNotModified; got != want {
+			t.Errorf("got HTTP %d with matching etag, want %d", got, want)
+		}
+
+		if got, want := head(`W/"0-0"`).Code, http.StatusOK; got != want {
+			t.Errorf("got HTTP %d with other etag, want %d", got, want)
+		}
 	})
 }
 
@@ -77,7 +123,7 @@ func TestAPI_TopOldCharacters(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		a := New(
 			&mock.Core{
-				TopOldCharactersFunc: func() ([]starwars.Character, error) {
+				TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
 					return []starwars.Character{
 						{Name: "Darth Vader", BirthYear: "41.9BBY"},
 						{Name: "Luke Skywalker", BirthYear: "19BBY"},
@@ -89,7 +135,7 @@ func TestAPI_TopOldCharacters(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
 
-		a.topOldCharacters(w, r)
+		a.characters(v1, ranking(starwars.Core.TopOldCharacters))(w, r)
 
 		if got, want := w.Code, http.StatusOK; got != want {
 			t.Errorf("got HTTP %d, want %d", got, want)
@@ -105,7 +151,7 @@ func TestAPI_TopOldCharacters(t *testing.T) {
 	t.Run("Error from core", func(t *testing.T) {
 		a := New(
 			&mock.Core{
-				TopOldCharactersFunc: func() ([]starwars.Character, error) {
+				TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
 					return nil, errors.New("foo error")
 				},
 			},
@@ -114,7 +160,7 @@ func TestAPI_TopOldCharacters(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/", nil)
 
-		a.topOldCharacters(w, r)
+		a.characters(v1, ranking(starwars.Core.TopOldCharacters))(w, r)
 
 		if got, want := w.Code, http.StatusInternalServerError; got != want {
 			t.Errorf("got HTTP %d, want %d", got, want)
@@ -127,10 +173,56 @@ func TestAPI_TopOldCharacters(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodPut, "/", nil)
 
-		a.topOldCharacters(w, r)
+		a.characters(v1, ranking(starwars.Core.TopOldCharacters))(w, r)
 
 		if got, want := w.Code, http.StatusMethodNotAllowed; got != want {
 			t.Errorf("got HTTP %d, want %d", got, want)
 		}
 	})
 }
+
+func TestRoutes(t *testing.T) {
+	routes := Routes()
+
+	for _, want := range []string{"/", "/v1/top-fat-characters", "/top-old-characters", "/charts/bmi.svg", "/metrics", "/admin/cache"} {
+		if !slices.Contains(routes, want) {
+			t.Errorf("routes do not contain %s: %v", want, routes)
+		}
+	}
+}
+
+// newTestMux returns a core serving the given characters as all characters and
+// as both rankings, with a populated cache, and a function making GET requests
+// with the given headers to the API of that core. The core may be changed
+// before making requests.
+func newTestMux(t *testing.T, characters []starwars.Character) (c *mock.Core, get func(path string, header map[string]string) *httptest.ResponseRecorder) {
+	t.Helper()
+
+	list := func(ctx context.Context) ([]starwars.Character, error) {
+		return characters, nil
+	}
+
+	c = &mock.Core{
+		CharactersFunc:       list,
+		TopFatCharactersFunc: list,
+		TopOldCharactersFunc: list,
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{Generation: 1}
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(c).Register(mux)
+
+	get = func(path string, header map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range header {
+			r.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	return c, get
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+)
+
+// Authenticator identifies clients by their credentials.
+type Authenticator interface {
+	Authenticate(credential string) (auth.Principal, error)
+}
+
+// WithAuth requires clients of the UI and ranking endpoints to authenticate
+// using an API key or a bearer token, given in the x-api-key or authorization
+// header, and to have been granted the read scope. It also enables the admin
+// endpoints, which require the admin scope. The health and metrics endpoints
+// remain anonymous.
+func WithAuth(authn Authenticator) Option {
+	return func(a *API) {
+		a.authn = authn
+	}
+}
+
+// WithAuthFailureLimit limits the failed authentication attempts of each IP
+// address, across all routes. Once the limit is reached, requests with
+// credentials from the address are rejected with 429 Too Many Requests
+// without checking the credentials, so that they cannot be guessed.
+func WithAuthFailureLimit(limit RateLimit) Option {
+	return func(a *API) {
+		a.authFailures = newLimiter(limit)
+	}
+}
+
+// authenticated identifies the client of each request using its credentials,
+// if any, and adds the principal to the request context. Requests with
+// invalid credentials are rejected.
+func (a *API) authenticated(h http.HandlerFunc) http.HandlerFunc {
+	if a.authn == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		credential, err := credential(r)
+		if err != nil {
+			unauthorized(w, r, "error_unsupported_authorization")
+			return
+		}
+
+		if credential == "" {
+			h(w, r)
+			return
+		}
+
+		if a.authFailures != nil {
+			if retryAfter := a.authFailures.wait(clientIP(r)); retryAfter > 0 {
+				w.Header().Set("retry-after", strconv.Itoa(seconds(retryAfter)))
+				httpError(w, r, http.StatusTooManyRequests, "error_rate_limited")
+				return
+			}
+		}
+
+		p, err := a.authn.Authenticate(credential)
+		if err != nil {
+			if a.authFailures != nil {
+				a.authFailures.allow(clientIP(r))
+			}
+
+			unauthorized(w, r, "error_invalid_credentials")
+			return
+		}
+
+		h(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	}
+}
+
+// authorized rejects requests from clients without the given scope.
+func (a *API) authorized(scope string, h http.HandlerFunc) http.HandlerFunc {
+	if a.authn == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, ok := auth.PrincipalFromContext(r.Context())
+		if !ok {
+			unauthorized(w, r, "error_authentication_required")
+			return
+		}
+
+		if !p.HasScope(scope) {
+			httpError(w, r, http.StatusForbidden, "error_missing_scope", scope)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// credential returns the API key or bearer token of r, or the empty string if
+// there is none.
+func credential(r *http.Request) (string, error) {
+	if key := r.Header.Get("x-api-key"); key != "" {
+		return key, nil
+	}
+
+	authorization := r.Header.Get("authorization")
+	if authorization == "" {
+		return "", nil
+	}
+
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "bearer") || strings.TrimSpace(token) == "" {
+		return "", errors.New("unsupported authorization scheme")
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request, key string) {
+	w.Header().Set("www-authenticate", `Bearer realm="starwars"`)
+	httpError(w, r, http.StatusUnauthorized, key)
+}
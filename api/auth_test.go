@@ -0,0 +1,137 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+type authenticatorFunc func(credential string) (auth.Principal, error)
+
+func (f authenticatorFunc) Authenticate(credential string) (auth.Principal, error) {
+	return f(credential)
+}
+
+func TestAPI_Auth(t *testing.T) {
+	authn := authenticatorFunc(func(credential string) (auth.Principal, error) {
+		switch credential {
+		case "reader":
+			return auth.Principal{ID: "reader", Scopes: []string{auth.ScopeRead}}, nil
+		case "nobody":
+			return auth.Principal{ID: "nobody"}, nil
+		default:
+			return auth.Principal{}, auth.ErrUnauthenticated
+		}
+	})
+
+	a := New(&mock.Core{
+		TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return nil, nil
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{}
+		},
+	}, WithAuth(authn))
+
+	mux := http.NewServeMux()
+	a.Register(mux)
+
+	for _, tc := range []struct {
+		desc     string
+		path     string
+		header   string
+		value    string
+		wantCode int
+	}{
+		{"Anonymous", "/top-fat-characters", "", "", http.StatusUnauthorized},
+		{"API key", "/top-fat-characters", "x-api-key", "reader", http.StatusOK},
+		{"Bearer token", "/top-fat-characters", "authorization", "Bearer reader", http.StatusOK},
+		{"Invalid credentials", "/top-fat-characters", "x-api-key", "foo", http.StatusUnauthorized},
+		{"Unsupported scheme", "/top-fat-characters", "authorization", "Basic cmVhZGVyOg==", http.StatusUnauthorized},
+		{"Missing scope", "/top-fat-characters", "x-api-key", "nobody", http.StatusForbidden},
+		{"Anonymous health check", "/healthz", "", "", http.StatusOK},
+		{"Invalid credentials for health check", "/healthz", "x-api-key", "foo", http.StatusUnauthorized},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+
+			if tc.header != "" {
+				r.Header.Set(tc.header, tc.value)
+			}
+
+			mux.ServeHTTP(w, r)
+
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("got HTTP %d, want %d", got, want)
+			}
+
+			if w.Code == http.StatusUnauthorized && w.Header().Get("www-authenticate") == "" {
+				t.Error("got no www-authenticate header")
+			}
+		})
+	}
+}
+
+func TestAPI_AuthFailureLimit(t *testing.T) {
+	var attempts int
+
+	authn := authenticatorFunc(func(credential string) (auth.Principal, error) {
+		attempts++
+
+		if credential == "reader" {
+			return auth.Principal{ID: "reader", Scopes: []string{auth.ScopeRead}}, nil
+		}
+
+		return auth.Principal{}, auth.ErrUnauthenticated
+	})
+
+	a := New(nil, WithAuth(authn), WithAuthFailureLimit(RateLimit{Rate: 1.0 / 60, Burst: 2}))
+
+	h := a.authenticated(func(w http.ResponseWriter, r *http.Request) {})
+
+	request := func(remoteAddr, credential string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if credential != "" {
+			r.Header.Set("x-api-key", credential)
+		}
+		h(w, r)
+		return w
+	}
+
+	for n, tc := range []struct {
+		credential string
+		wantCode   int
+	}{
+		{"reader", http.StatusOK},
+		{"foo", http.StatusUnauthorized},
+		{"reader", http.StatusOK},
+		{"bar", http.StatusUnauthorized},
+		{"baz", http.StatusTooManyRequests},
+		{"reader", http.StatusTooManyRequests},
+		{"", http.StatusOK},
+	} {
+		if got, want := request("192.0.2.1:1234", tc.credential).Code, tc.wantCode; got != want {
+			t.Errorf("[%d] got HTTP %d, want %d", n, got, want)
+		}
+	}
+
+	if got, want := attempts, 4; got != want {
+		t.Errorf("got %d authentication attempts, want %d", got, want)
+	}
+
+	if got, want := request("192.0.2.1:1234", "foo").Header().Get("retry-after"), "60"; got != want {
+		t.Errorf("got retry-after %q, want %q", got, want)
+	}
+
+	if got, want := request("192.0.2.2:1234", "reader").Code, http.StatusOK; got != want {
+		t.Errorf("got HTTP %d for other IP, want %d", got, want)
+	}
+}
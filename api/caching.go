@@ -0,0 +1,164 @@
+package api
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// conditional wraps h with HTTP caching headers derived from the cache status
+// of the core, and answers conditional requests with 304 Not Modified if the
+// client already has the current representation. Requests with invalid query
+// parameters are left to h to reject, as there is no such representation.
+func (a *API) conditional(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead || !validQuery(r) {
+			h(w, r)
+			return
+		}
+
+		// Only answer from the client's copy if the cache is fresh; stale data
+		// is refetched by the handler, which yields a new generation.
+		if status := a.core.CacheStatus(); status.Fresh(time.Now()) {
+			setCachingHeaders(w, r, status)
+
+			if notModified(r, w.Header().Get("etag"), lastModified(status)) {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		h(&cachingResponseWriter{ResponseWriter: w, r: r, core: a.core}, r)
+	}
+}
+
+// cachingResponseWriter sets caching headers on successful responses based on
+// the cache status at the time the response is written, which reflects any
+// data fetched by the handler.
+type cachingResponseWriter struct {
+	http.ResponseWriter
+	r           *http.Request
+	core        starwars.Core
+	wroteHeader bool
+}
+
+func (cw *cachingResponseWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.wroteHeader = true
+
+		if code == http.StatusOK {
+			if status := cw.core.CacheStatus(); status.Generation > 0 {
+				setCachingHeaders(cw.ResponseWriter, cw.r, status)
+			}
+		} else {
+			cw.Header().Del("etag")
+			cw.Header().Del("last-modified")
+			cw.Header().Set("cache-control", "no-store")
+		}
+	}
+
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *cachingResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *cachingResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// validQuery reports whether the query parameters of r that select a
+// representation are valid.
+func validQuery(r *http.Request) bool {
+	if _, _, err := requestedUnits(r, false); err != nil {
+		return false
+	}
+
+	_, _, ok := requestedFields(r)
+	return ok
+}
+
+func setCachingHeaders(w http.ResponseWriter, r *http.Request, status starwars.CacheStatus) {
+	w.Header().Set("etag", etag(r, status))
+
+	// The entity tag depends on the locale and units, which may be chosen by
+	// cookie, so caches must tell them apart also on 304 Not Modified.
+	vary(w, "Accept-Language", "Cookie")
+	w.Header().Set("last-modified", lastModified(status).Format(http.TimeFormat))
+
+	if status.TTL == 0 {
+		w.Header().Set("cache-control", "no-cache")
+		return
+	}
+
+	maxAge := status.TTL - status.Age(time.Now())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+
+	w.Header().Set("cache-control", fmt.Sprintf("max-age=%d", int(maxAge.Seconds())))
+}
+
+// etag returns a weak entity tag identifying the representation of r, in its
+// locale and units, at the given cache generation.
+func etag(r *http.Request, status starwars.CacheStatus) string {
+	u, _, _ := requestedUnits(r, true)
+
+	h := fnv.New32a()
+	h.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery + "#" + locale(r).Tag + "#" + u.Name))
+	return fmt.Sprintf(`W/"%d-%08x"`, status.Generation, h.Sum32())
+}
+
+// vary adds the given request headers to the vary header of w, unless they
+// are already listed.
+func vary(w http.ResponseWriter, headers ...string) {
+	for _, header := range headers {
+		if !slices.Contains(w.Header().Values("vary"), header) {
+			w.Header().Add("vary", header)
+		}
+	}
+}
+
+func lastModified(status starwars.CacheStatus) time.Time {
+	return status.Updated.UTC().Truncate(time.Second)
+}
+
+// notModified reports whether the preconditions in r show that the client
+// already has the representation with the given entity tag and modification
+// time. If-Modified-Since is ignored if If-None-Match is present.
+func notModified(r *http.Request, etag string, modified time.Time) bool {
+	if inm := r.Header.Get("if-none-match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || weakMatch(tag, etag) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := r.Header.Get("if-modified-since"); ims != "" {
+		t, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !modified.After(t)
+	}
+
+	return false
+}
+
+// weakMatch compares two entity tags using the weak comparison function of
+// RFC 9110, which ignores the weakness indicator.
+func weakMatch(a, b string) bool {
+	return strings.TrimPrefix(a, "W/") == strings.TrimPrefix(b, "W/")
+}
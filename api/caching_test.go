@@ -0,0 +1,305 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Conditional(t *testing.T) {
+	updated := time.Now().Add(-10 * time.Minute).UTC().Truncate(time.Second)
+
+	newAPI := func(status starwars.CacheStatus, handlerCalls *int) http.HandlerFunc {
+		a := New(
+			&mock.Core{
+				CacheStatusFunc: func() starwars.CacheStatus {
+					return status
+				},
+			},
+		)
+
+		return a.conditional(func(w http.ResponseWriter, r *http.Request) {
+			*handlerCalls++
+			w.Write([]byte("foo"))
+		})
+	}
+
+	t.Run("Caching headers", func(t *testing.T) {
+		var calls int
+
+		h := newAPI(starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Hour}, &calls)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+
+		h(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := w.Header().Get("etag"), `W/"3-`; !strings.HasPrefix(got, want) {
+			t.Errorf("got etag %q, want prefix %q", got, want)
+		}
+
+		if got, want := w.Header().Get("last-modified"), updated.Format(http.TimeFormat); got != want {
+			t.Errorf("got last-modified %q, want %q", got, want)
+		}
+
+		// Allow a second of slack for slow test runs
+		if got := w.Header().Get("cache-control"); got != "max-age=3000" && got != "max-age=2999" {
+			t.Errorf("got cache-control %q, want %q", got, "max-age=3000")
+		}
+
+		if got, want := calls, 1; got != want {
+			t.Errorf("handler called %d times, want %d", got, want)
+		}
+	})
+
+	t.Run("No expiry", func(t *testing.T) {
+		var calls int
+
+		h := newAPI(starwars.CacheStatus{Generation: 1, Updated: updated}, &calls)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+		h(w, r)
+
+		if got, want := w.Header().Get("cache-control"), "no-cache"; got != want {
+			t.Errorf("got cache-control %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Cold cache", func(t *testing.T) {
+		var calls int
+
+		h := newAPI(starwars.CacheStatus{}, &calls)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("if-none-match", "*")
+
+		h(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got := w.Header().Get("etag"); got != "" {
+			t.Errorf("got etag %q, want none", got)
+		}
+
+		if got, want := calls, 1; got != want {
+			t.Errorf("handler called %d times, want %d", got, want)
+		}
+	})
+
+	t.Run("If-None-Match", func(t *testing.T) {
+		status := starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Hour}
+
+		var calls int
+
+		h := newAPI(status, &calls)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+
+		h(w, r)
+
+		currentETag := w.Header().Get("etag")
+
+		otherPathETag := etag(httptest.NewRequest(http.MethodGet, "/top-old-characters", nil), status)
+
+		for n, tc := range []struct {
+			ifNoneMatch string
+			wantCode    int
+		}{
+			{currentETag, http.StatusNotModified},
+			{`"foo", ` + currentETag, http.StatusNotModified},
+			{currentETag[2:], http.StatusNotModified},
+			{"*", http.StatusNotModified},
+			{`W/"2-00000000"`, http.StatusOK},
+			{otherPathETag, http.StatusOK},
+		} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+			r.Header.Set("if-none-match", tc.ifNoneMatch)
+			r.Header.Set("if-modified-since", updated.Format(http.TimeFormat))
+
+			h(w, r)
+
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("[%d] If-None-Match %s: got HTTP %d, want %d", n, tc.ifNoneMatch, got, want)
+			}
+
+			if tc.wantCode == http.StatusNotModified && w.Body.Len() != 0 {
+				t.Errorf("[%d] got body %q, want none", n, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		var calls int
+
+		h := newAPI(starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Hour}, &calls)
+
+		for n, tc := range []struct {
+			ifModifiedSince string
+			wantCode        int
+		}{
+			{updated.Format(http.TimeFormat), http.StatusNotModified},
+			{updated.Add(time.Second).Format(http.TimeFormat), http.StatusNotModified},
+			{updated.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+			{"foo", http.StatusOK},
+		} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("if-modified-since", tc.ifModifiedSince)
+
+			h(w, r)
+
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("[%d] If-Modified-Since %s: got HTTP %d, want %d", n, tc.ifModifiedSince, got, want)
+			}
+		}
+	})
+
+	t.Run("Stale cache", func(t *testing.T) {
+		var calls int
+
+		h := newAPI(starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Minute}, &calls)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("if-none-match", "*")
+
+		h(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := w.Header().Get("cache-control"), "max-age=0"; got != want {
+			t.Errorf("got cache-control %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Invalid query", func(t *testing.T) {
+		a := New(
+			&mock.Core{
+				TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+					return nil, nil
+				},
+				CacheStatusFunc: func() starwars.CacheStatus {
+					return starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Hour}
+				},
+			},
+		)
+
+		h := a.conditional(a.characters(v1, ranking(starwars.Core.TopFatCharacters)))
+
+		for _, tc := range []struct {
+			path     string
+			wantCode int
+		}{
+			{"/v1/top-fat-characters?units=imperial", http.StatusNotModified},
+			{"/v1/top-fat-characters?units=furlongs", http.StatusBadRequest},
+			{"/v1/top-fat-characters?fields=name", http.StatusNotModified},
+			{"/v1/top-fat-characters?fields=foo", http.StatusBadRequest},
+		} {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			r.Header.Set("if-none-match", "*")
+
+			h(w, r)
+
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("%s: got HTTP %d, want %d", tc.path, got, want)
+			}
+		}
+	})
+
+	t.Run("Vary", func(t *testing.T) {
+		list := func(ctx context.Context) ([]starwars.Character, error) {
+			return nil, nil
+		}
+
+		c := &mock.Core{
+			CharactersFunc:       list,
+			TopFatCharactersFunc: list,
+			TopOldCharactersFunc: list,
+			CacheStatusFunc: func() starwars.CacheStatus {
+				return starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Hour}
+			},
+		}
+
+		mux := http.NewServeMux()
+		New(c, WithCompression(0)).Register(mux)
+
+		for _, path := range []string{"/", "/v1/top-fat-characters"} {
+			var vary [2]string
+
+			for n, ifNoneMatch := range []string{"", "*"} {
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodGet, path, nil)
+				if ifNoneMatch != "" {
+					r.Header.Set("if-none-match", ifNoneMatch)
+				}
+
+				mux.ServeHTTP(w, r)
+
+				if got, want := w.Code, []int{http.StatusOK, http.StatusNotModified}[n]; got != want {
+					t.Fatalf("%s: got HTTP %d, want %d", path, got, want)
+				}
+
+				vary[n] = strings.Join(w.Header().Values("vary"), ", ")
+			}
+
+			if got, want := vary[1], "Accept-Encoding, Accept-Language, Cookie"; got != want {
+				t.Errorf("%s: got vary %q on 304, want %q", path, got, want)
+			}
+
+			if got, want := vary[1], vary[0]; got != want {
+				t.Errorf("%s: got vary %q on 304, want %q as on 200", path, got, want)
+			}
+		}
+	})
+
+	t.Run("Error response", func(t *testing.T) {
+		a := New(
+			&mock.Core{
+				TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+					return nil, errors.New("foo error")
+				},
+				CacheStatusFunc: func() starwars.CacheStatus {
+					return starwars.CacheStatus{Generation: 3, Updated: updated, TTL: time.Hour}
+				},
+			},
+		)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+
+		a.conditional(a.characters(v1, ranking(starwars.Core.TopFatCharacters)))(w, r)
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got := w.Header().Get("etag"); got != "" {
+			t.Errorf("got etag %q, want none", got)
+		}
+
+		if got, want := w.Header().Get("cache-control"), "no-store"; got != want {
+			t.Errorf("got cache-control %q, want %q", got, want)
+		}
+	})
+}
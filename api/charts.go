@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/jsageryd/starwars-coding-test/chart"
+	"github.com/jsageryd/starwars-coding-test/i18n"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+	"github.com/jsageryd/starwars-coding-test/tracing"
+)
+
+// svgContentSecurityPolicy forbids charts from loading or running anything,
+// should they be opened on their own.
+const svgContentSecurityPolicy = "default-src 'none'"
+
+// characterChart is an SVG chart of characters, served at
+// /charts/<name>.svg.
+type characterChart struct {
+	name   string
+	render func(w io.Writer, cs []starwars.Character, l *i18n.Locale, u unitSystem) error
+}
+
+var characterCharts = []characterChart{
+	{"bmi", bmiHistogram},
+	{"height-mass", heightMassScatter},
+	{"birth-years", birthYearTimeline},
+}
+
+func bmiHistogram(w io.Writer, cs []starwars.Character, l *i18n.Locale, _ unitSystem) error {
+	var bmis []float64
+
+	for _, c := range cs {
+		if bmi, ok := c.BMI(); ok {
+			bmis = append(bmis, bmi)
+		}
+	}
+
+	return chart.Chart{
+		Title:    l.T("chart_bmi_title"),
+		XLabel:   l.T("chart_bmi_x"),
+		YLabel:   l.T("chart_characters"),
+		BarLabel: l.T("chart_bmi_bar"),
+	}.Histogram(w, bmis, 5)
+}
+
+func heightMassScatter(w io.Writer, cs []starwars.Character, l *i18n.Locale, u unitSystem) error {
+	var points []chart.Point
+
+	for _, c := range cs {
+		if c.Height > 0 && c.Mass > 0 {
+			height, mass := u.Height(c.Height), u.Mass(c.Mass)
+
+			points = append(points, chart.Point{
+				X: height,
+				Y: mass,
+				Label: fmt.Sprintf("%s (%s, %s)", c.Name,
+					l.T("quantity", l.Number(height, -1), u.HeightUnit),
+					l.T("quantity", l.Number(mass, -1), u.MassUnit)),
+			})
+		}
+	}
+
+	return chart.Chart{
+		Title:  l.T("chart_height_mass_title"),
+		XLabel: l.T("chart_height_x", u.HeightUnit),
+		YLabel: l.T("chart_mass_y", u.MassUnit),
+	}.Scatter(w, points)
+}
+
+func birthYearTimeline(w io.Writer, cs []starwars.Character, l *i18n.Locale, _ unitSystem) error {
+	var points []chart.Point
+
+	for _, c := range cs {
+		if year, err := starwars.ParseBirthYear(c.BirthYear); err == nil {
+			points = append(points, chart.Point{
+				X:     year,
+				Label: c.Name + " (" + c.BirthYear + ")",
+			})
+		}
+	}
+
+	return chart.Chart{
+		Title:  l.T("chart_birth_years_title"),
+		XLabel: l.T("chart_birth_years_x"),
+		Height: 240,
+	}.Timeline(w, points)
+}
+
+type chartFigure struct {
+	URL   string
+	Title string
+}
+
+// figure returns the named chart for showing in a page.
+func figure(name, title string) chartFigure {
+	return chartFigure{URL: "/charts/" + name + ".svg", Title: title}
+}
+
+// chart returns a handler rendering c from all characters. With download=1 the
+// chart is served as an attachment.
+func (a *API) chart(c characterChart) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, _, err := requestedUnits(r, true)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error_invalid_units")
+			return
+		}
+
+		characters, err := a.core.Characters(r.Context())
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "error_unknown")
+			slog.ErrorContext(r.Context(), "Error fetching characters", "error", err)
+			return
+		}
+
+		var buf bytes.Buffer
+
+		l := locale(r)
+
+		_, span := tracing.Start(r.Context(), "render chart "+c.name, tracing.KindInternal, tracing.Attr("locale", l.Tag))
+		err = c.render(&buf, characters, l, u)
+		span.RecordError(err)
+		span.End()
+
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "error_rendering_chart")
+			slog.ErrorContext(r.Context(), "Error rendering chart", "chart", c.name, "error", err)
+			return
+		}
+
+		setLanguageHeaders(w, r, l)
+		w.Header().Set("content-type", "image/svg+xml")
+		w.Header().Set("content-security-policy", svgContentSecurityPolicy)
+		w.Header().Set("x-content-type-options", "nosniff")
+
+		if r.URL.Query().Get("download") == "1" {
+			w.Header().Set("content-disposition", `attachment; filename="`+c.name+`.svg"`)
+		}
+
+		buf.WriteTo(w)
+	}
+}
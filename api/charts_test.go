@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Charts(t *testing.T) {
+	c := &mock.Core{
+		CharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{
+				{Name: "Luke Skywalker", Height: 172, Mass: 77, BirthYear: "19BBY"},
+				{Name: "<Yoda>", Height: 66, Mass: 17, BirthYear: "896BBY"},
+				{Name: "Jabba Desilijic Tiure", Height: 175, Mass: 1358, BirthYear: "600BBY"},
+				{Name: "Arvel Crynyd"},
+			}, nil
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{}
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(c).Register(mux)
+
+	for _, tc := range []struct {
+		path string
+		want []string
+	}{
+		{"/charts/bmi.svg", []string{"<title>BMI of Star Wars characters</title>", "<title>25 to 30: 1</title>", "<title>440 to 445: 1</title>"}},
+		{"/charts/bmi.svg?lang=de", []string{"<title>BMI der Star-Wars-Figuren</title>", "<title>25 bis 30: 1</title>", "<title>440 bis 445: 1</title>"}},
+		{"/charts/height-mass.svg", []string{"<title>Luke Skywalker (172 cm, 77 kg)</title>", "<title>&lt;Yoda&gt; (66 cm, 17 kg)</title>"}},
+		{"/charts/birth-years.svg", []string{"<title>Luke Skywalker (19BBY)</title>", "<title>&lt;Yoda&gt; (896BBY)</title>"}},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.path, nil))
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			if got, want := w.Header().Get("content-type"), "image/svg+xml"; got != want {
+				t.Errorf("got content-type %q, want %q", got, want)
+			}
+
+			if got := w.Header().Get("content-disposition"); got != "" {
+				t.Errorf("got content-disposition %q, want none", got)
+			}
+
+			body := w.Body.String()
+
+			if !strings.HasPrefix(body, "<svg ") {
+				t.Errorf("body is not an SVG document:\n%s", body)
+			}
+
+			for _, want := range tc.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("body does not contain %q:\n%s", want, body)
+				}
+			}
+		})
+	}
+
+	t.Run("Download", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/charts/bmi.svg?download=1", nil))
+
+		if got, want := w.Header().Get("content-disposition"), `attachment; filename="bmi.svg"`; got != want {
+			t.Errorf("got content-disposition %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Embedded in UI", func(t *testing.T) {
+		c := &mock.Core{
+			TopFatCharactersFunc: c.CharactersFunc,
+			TopOldCharactersFunc: c.CharactersFunc,
+			CacheStatusFunc:      c.CacheStatusFunc,
+		}
+
+		mux := http.NewServeMux()
+		New(c).Register(mux)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		for _, want := range []string{
+			`<img src="/charts/bmi.svg" alt="BMI histogram">`,
+			`<img src="/charts/height-mass.svg"`,
+			`<img src="/charts/birth-years.svg"`,
+			`<a href="/charts/bmi.svg?download=1" download>`,
+		} {
+			if !strings.Contains(w.Body.String(), want) {
+				t.Errorf("body does not contain %q:\n%s", want, w.Body.String())
+			}
+		}
+	})
+}
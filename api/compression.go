@@ -0,0 +1,314 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Encoder is a content coding responses can be compressed with.
+type Encoder struct {
+	Name      string                           // content coding, e.g. "gzip"
+	NewWriter func(w io.Writer) io.WriteCloser // returns a writer compressing to w
+}
+
+var gzipWriters = sync.Pool{
+	New: func() any {
+		return gzip.NewWriter(io.Discard)
+	},
+}
+
+// pooledGzipWriter returns its gzip.Writer to the pool when closed.
+type pooledGzipWriter struct {
+	*gzip.Writer
+}
+
+func (w pooledGzipWriter) Close() error {
+	err := w.Writer.Close()
+	gzipWriters.Put(w.Writer)
+	return err
+}
+
+// Gzip compresses responses using gzip.
+var Gzip = Encoder{
+	Name: "gzip",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		gw := gzipWriters.Get().(*gzip.Writer)
+		gw.Reset(w)
+		return pooledGzipWriter{gw}
+	},
+}
+
+var zlibWriters = sync.Pool{
+	New: func() any {
+		return zlib.NewWriter(io.Discard)
+	},
+}
+
+// pooledZlibWriter returns its zlib.Writer to the pool when closed.
+type pooledZlibWriter struct {
+	*zlib.Writer
+}
+
+func (w pooledZlibWriter) Close() error {
+	err := w.Writer.Close()
+	zlibWriters.Put(w.Writer)
+	return err
+}
+
+// Deflate compresses responses using the deflate content coding, which is
+// deflate in the zlib format.
+var Deflate = Encoder{
+	Name: "deflate",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		zw := zlibWriters.Get().(*zlib.Writer)
+		zw.Reset(w)
+		return pooledZlibWriter{zw}
+	},
+}
+
+// WithCompression compresses responses of at least minSize bytes using the
+// encoder most preferred by the client according to its Accept-Encoding
+// header. Where the client has no preference, encoders are preferred in the
+// given order. Without encoders, Gzip and Deflate are used.
+func WithCompression(minSize int, encoders ...Encoder) Option {
+	return func(a *API) {
+		if len(encoders) == 0 {
+			encoders = []Encoder{Gzip, Deflate}
+		}
+		a.compression = &compression{minSize: minSize, encoders: encoders}
+	}
+}
+
+type compression struct {
+	minSize  int
+	encoders []Encoder
+}
+
+// compressed compresses the responses of h as negotiated with the client.
+func (a *API) compressed(h http.HandlerFunc) http.HandlerFunc {
+	if a.compression == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		vary(w, "Accept-Encoding")
+
+		enc, ok := a.compression.negotiate(r.Header.Get("accept-encoding"))
+		if !ok || r.Method == http.MethodHead {
+			h(w, r)
+			return
+		}
+
+		cw := &compressingResponseWriter{
+			ResponseWriter: w,
+			enc:            enc,
+			minSize:        a.compression.minSize,
+		}
+		defer cw.close()
+
+		h(cw, r)
+	}
+}
+
+// negotiate returns the encoder to use for a request with the given
+// Accept-Encoding header, or ok=false if the response should not be encoded.
+func (c *compression) negotiate(acceptEncoding string) (enc Encoder, ok bool) {
+	if acceptEncoding == "" {
+		return Encoder{}, false
+	}
+
+	weights := make(map[string]float64)
+
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+
+		q := 1.0
+
+		if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				q = f
+			}
+		}
+
+		weights[name] = q
+	}
+
+	best := 0.0
+
+	for _, e := range c.encoders {
+		q, found := weights[e.Name]
+		if !found {
+			q, found = weights["*"]
+		}
+
+		if found && q > best {
+			enc, best, ok = e, q, true
+		}
+	}
+
+	return enc, ok
+}
+
+// compressingResponseWriter buffers the start of the response to decide
+// whether it is worth compressing, then writes it either compressed or as is.
+// Flushing decides on compression early, so that streamed responses are
+// delivered as they are written.
+type compressingResponseWriter struct {
+	http.ResponseWriter
+	enc     Encoder
+	minSize int
+
+	code        int
+	wroteHeader bool
+	decided     bool
+	buf         []byte
+	zw          io.WriteCloser // nil if not compressing
+}
+
+func (cw *compressingResponseWriter) WriteHeader(code int) {
+	if cw.wroteHeader {
+		return
+	}
+
+	cw.wroteHeader = true
+	cw.code = code
+
+	// Responses without a body, and informational ones, are passed on as is.
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+		cw.decide(false)
+	}
+}
+
+func (cw *compressingResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.buf = append(cw.buf, b...)
+
+		if len(cw.buf) < cw.minSize {
+			return len(b), nil
+		}
+
+		if err := cw.decide(cw.compressible()); err != nil {
+			return 0, err
+		}
+
+		return len(b), nil
+	}
+
+	if cw.zw != nil {
+		return cw.zw.Write(b)
+	}
+
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends what has been written so far to the client.
+func (cw *compressingResponseWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if !cw.decided {
+		cw.decide(cw.compressible())
+	}
+
+	if f, ok := cw.zw.(interface{ Flush() error }); ok {
+		f.Flush()
+	}
+
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressingResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// compressible reports whether the response should be compressed, judging by
+// its headers.
+func (cw *compressingResponseWriter) compressible() bool {
+	h := cw.Header()
+
+	if h.Get("content-encoding") != "" {
+		return false
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(h.Get("content-type"))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(cw.buf)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "json"),
+		strings.HasSuffix(mediaType, "xml"),
+		mediaType == "application/javascript":
+		return true
+	default:
+		return false
+	}
+}
+
+// decide writes the header, compressed or not, and anything buffered.
+func (cw *compressingResponseWriter) decide(compress bool) error {
+	cw.decided = true
+
+	if compress {
+		h := cw.Header()
+
+		h.Set("content-encoding", cw.enc.Name)
+		h.Del("content-length")
+
+		// Weak ETags hold across encodings, strong ones must differ.
+		if etag := h.Get("etag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("etag", strings.TrimSuffix(etag, `"`)+"-"+cw.enc.Name+`"`)
+		}
+
+		cw.zw = cw.enc.NewWriter(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(cw.code)
+
+	if len(cw.buf) == 0 {
+		return nil
+	}
+
+	buf := cw.buf
+	cw.buf = nil
+
+	var err error
+
+	if cw.zw != nil {
+		_, err = cw.zw.Write(buf)
+	} else {
+		_, err = cw.ResponseWriter.Write(buf)
+	}
+
+	return err
+}
+
+// close writes anything still buffered, uncompressed as it is below the
+// minimum size, and finishes the compressed stream.
+func (cw *compressingResponseWriter) close() {
+	if !cw.wroteHeader {
+		return
+	}
+
+	if !cw.decided {
+		cw.decide(false)
+	}
+
+	if cw.zw != nil {
+		cw.zw.Close()
+	}
+}
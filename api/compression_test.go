@@ -0,0 +1,276 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestCompression_Negotiate(t *testing.T) {
+	c := &compression{encoders: []Encoder{Gzip, Deflate}}
+
+	for _, tc := range []struct {
+		acceptEncoding string
+		want           string // empty for no encoding
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"deflate, gzip", "gzip"},
+		{"deflate, gzip;q=0.5", "deflate"},
+		{"gzip;q=0, deflate;q=0.1", "deflate"},
+		{"gzip;q=0", ""},
+		{"br", ""},
+		{"*", "gzip"},
+		{"*, gzip;q=0", "deflate"},
+		{"identity", ""},
+		{"GZIP", "gzip"},
+	} {
+		enc, _ := c.negotiate(tc.acceptEncoding)
+
+		if got, want := enc.Name, tc.want; got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", tc.acceptEncoding, got, want)
+		}
+	}
+}
+
+func TestAPI_Compression(t *testing.T) {
+	var characters []starwars.Character
+	for n := 0; n < 50; n++ {
+		characters = append(characters, starwars.Character{Name: fmt.Sprintf("Clone trooper %d", n), Height: 183, Mass: 80})
+	}
+
+	status := starwars.CacheStatus{Generation: 1, Entries: 50, Updated: time.Now(), TTL: time.Hour}
+
+	c := &mock.Core{
+		TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return characters, nil
+		},
+		TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return characters[:1], nil
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return status
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(c, WithCompression(1024)).Register(mux)
+
+	get := func(path, acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if acceptEncoding != "" {
+			r.Header.Set("accept-encoding", acceptEncoding)
+		}
+		if ifNoneMatch != "" {
+			r.Header.Set("if-none-match", ifNoneMatch)
+		}
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	plain := get("/top-fat-characters", "", "")
+
+	t.Run("Success", func(t *testing.T) {
+		w := get("/top-fat-characters", "gzip", "")
+
+		if got, want := w.Header().Get("content-encoding"), "gzip"; got != want {
+			t.Fatalf("got content-encoding %q, want %q", got, want)
+		}
+
+		if got, want := w.Header().Get("vary"), "Accept-Encoding"; got != want {
+			t.Errorf("got vary %q, want %q", got, want)
+		}
+
+		if got, want := w.Header().Get("content-type"), "application/json"; got != want {
+			t.Errorf("got content-type %q, want %q", got, want)
+		}
+
+		zr, err := gzip.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := string(body), plain.Body.String(); got != want {
+			t.Errorf("got body:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Deflate", func(t *testing.T) {
+		w := get("/top-fat-characters", "gzip;q=0.5, deflate", "")
+
+		if got, want := w.Header().Get("content-encoding"), "deflate"; got != want {
+			t.Fatalf("got content-encoding %q, want %q", got, want)
+		}
+
+		zr, err := zlib.NewReader(w.Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := string(body), plain.Body.String(); got != want {
+			t.Errorf("got body:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Not accepted", func(t *testing.T) {
+		if got := plain.Header().Get("content-encoding"); got != "" {
+			t.Errorf("got content-encoding %q, want none", got)
+		}
+
+		if got, want := plain.Header().Get("vary"), "Accept-Encoding"; got != want {
+			t.Errorf("got vary %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Below minimum size", func(t *testing.T) {
+		w := get("/top-old-characters", "gzip", "")
+
+		if got := w.Header().Get("content-encoding"); got != "" {
+			t.Errorf("got content-encoding %q, want none", got)
+		}
+
+		if !strings.Contains(w.Body.String(), "Clone trooper 0") {
+			t.Errorf("got body %q, want it uncompressed", w.Body.String())
+		}
+	})
+
+	t.Run("ETag", func(t *testing.T) {
+		w := get("/top-fat-characters", "gzip", "")
+
+		etag := w.Header().Get("etag")
+
+		if got, want := etag, plain.Header().Get("etag"); got != want {
+			t.Errorf("got etag %q, want %q as for the uncompressed response", got, want)
+		}
+
+		w = get("/top-fat-characters", "gzip", etag)
+
+		if got, want := w.Code, http.StatusNotModified; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got := w.Header().Get("content-encoding"); got != "" {
+			t.Errorf("got content-encoding %q for 304, want none", got)
+		}
+
+		if got := w.Body.Len(); got != 0 {
+			t.Errorf("got %d bytes of body for 304, want none", got)
+		}
+	})
+}
+
+func TestAPI_Compressed(t *testing.T) {
+	a := New(nil, WithCompression(10))
+
+	t.Run("Streaming", func(t *testing.T) {
+		first := strings.Repeat("foo ", 10)
+
+		var flushedBody []byte
+
+		rec := httptest.NewRecorder()
+
+		h := a.compressed(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "text/plain")
+			w.Write([]byte(first))
+			http.NewResponseController(w).Flush()
+
+			flushedBody = append([]byte(nil), rec.Body.Bytes()...)
+
+			w.Write([]byte("bar"))
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("accept-encoding", "gzip")
+
+		h(rec, r)
+
+		if !rec.Flushed {
+			t.Error("response not flushed")
+		}
+
+		zr, err := gzip.NewReader(strings.NewReader(string(flushedBody)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		got := make([]byte, len(first))
+
+		if _, err := io.ReadFull(zr, got); err != nil {
+			t.Fatalf("error reading flushed data: %v", err)
+		}
+
+		if string(got) != first {
+			t.Errorf("got flushed data %q, want %q", got, first)
+		}
+
+		zr, err = gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := string(body), first+"bar"; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Strong ETag", func(t *testing.T) {
+		h := a.compressed(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("etag", `"foo"`)
+			w.Header().Set("content-type", "text/plain")
+			w.Write([]byte(strings.Repeat("foo ", 10)))
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("accept-encoding", "gzip")
+
+		h(w, r)
+
+		if got, want := w.Header().Get("etag"), `"foo-gzip"`; got != want {
+			t.Errorf("got etag %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Incompressible", func(t *testing.T) {
+		h := a.compressed(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("content-type", "image/png")
+			w.Write([]byte(strings.Repeat("foo ", 10)))
+		})
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("accept-encoding", "gzip")
+
+		h(w, r)
+
+		if got := w.Header().Get("content-encoding"); got != "" {
+			t.Errorf("got content-encoding %q, want none", got)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures cross-origin resource sharing, letting browser-based
+// clients on other origins call the API.
+type CORS struct {
+	AllowedOrigins   []string      // origins allowed, e.g. "https://example.com", or "*" for any
+	AllowedMethods   []string      // methods allowed in addition to GET, HEAD and POST
+	AllowedHeaders   []string      // request headers allowed beyond the CORS-safelisted ones
+	ExposedHeaders   []string      // response headers readable by clients
+	AllowCredentials bool          // whether requests may carry cookies or authorization headers
+	MaxAge           time.Duration // how long preflight responses may be cached, 0 for the browser default
+}
+
+// WithCORS handles CORS preflight requests and adds CORS headers to responses
+// to requests from allowed origins. It panics if credentials are allowed from
+// any origin, which would let any site make requests on behalf of users.
+func WithCORS(cors CORS) Option {
+	if cors.AllowCredentials && slices.Contains(cors.AllowedOrigins, "*") {
+		panic("api: CORS credentials cannot be allowed from any origin (*), list the allowed origins instead")
+	}
+
+	return func(a *API) {
+		a.cors = &cors
+	}
+}
+
+func (c *CORS) allowOrigin(origin string) bool {
+	return slices.Contains(c.AllowedOrigins, "*") || slices.Contains(c.AllowedOrigins, origin)
+}
+
+func (c *CORS) allowMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+	return slices.Contains(c.AllowedMethods, method)
+}
+
+func (c *CORS) allowHeaders(headers string) bool {
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		if !slices.ContainsFunc(c.AllowedHeaders, func(allowed string) bool {
+			return strings.EqualFold(allowed, h)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// withCORS applies the CORS configuration, if any, to h. Preflight requests
+// are answered without calling h, so that they need no credentials.
+func (a *API) withCORS(h http.HandlerFunc) http.HandlerFunc {
+	if a.cors == nil {
+		return h
+	}
+
+	c := a.cors
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("origin")
+
+		preflight := r.Method == http.MethodOptions && r.Header.Get("access-control-request-method") != ""
+
+		if preflight {
+			w.Header().Add("vary", "Origin, Access-Control-Request-Method, Access-Control-Request-Headers")
+		} else {
+			w.Header().Add("vary", "Origin")
+		}
+
+		if origin == "" {
+			h(w, r)
+			return
+		}
+
+		if !c.allowOrigin(origin) {
+			if preflight {
+				httpError(w, r, http.StatusForbidden, "error_origin_not_allowed")
+				return
+			}
+			h(w, r)
+			return
+		}
+
+		if slices.Contains(c.AllowedOrigins, "*") {
+			w.Header().Set("access-control-allow-origin", "*")
+		} else {
+			w.Header().Set("access-control-allow-origin", origin)
+		}
+
+		if c.AllowCredentials {
+			w.Header().Set("access-control-allow-credentials", "true")
+		}
+
+		if !preflight {
+			if len(c.ExposedHeaders) > 0 {
+				w.Header().Set("access-control-expose-headers", strings.Join(c.ExposedHeaders, ", "))
+			}
+			h(w, r)
+			return
+		}
+
+		method := r.Header.Get("access-control-request-method")
+		headers := r.Header.Get("access-control-request-headers")
+
+		if !c.allowMethod(method) || !c.allowHeaders(headers) {
+			httpError(w, r, http.StatusForbidden, "error_cors_not_allowed")
+			return
+		}
+
+		w.Header().Set("access-control-allow-methods", method)
+
+		if headers != "" {
+			w.Header().Set("access-control-allow-headers", headers)
+		}
+
+		if c.MaxAge > 0 {
+			w.Header().Set("access-control-max-age", strconv.Itoa(int(c.MaxAge.Seconds())))
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_CORS(t *testing.T) {
+	c := &mock.Core{
+		TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{{Name: "Luke Skywalker", Height: 172, Mass: 77}}, nil
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{}
+		},
+	}
+
+	cors := CORS{
+		AllowedOrigins: []string{"https://dashboard.example.com"},
+		AllowedMethods: []string{http.MethodDelete},
+		AllowedHeaders: []string{"Authorization", "X-API-Key"},
+		ExposedHeaders: []string{"ETag", "X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	}
+
+	newMux := func(opts ...Option) *http.ServeMux {
+		mux := http.NewServeMux()
+		New(c, opts...).Register(mux)
+		return mux
+	}
+
+	t.Run("Simple request", func(t *testing.T) {
+		mux := newMux(WithCORS(cors))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+		r.Header.Set("origin", "https://dashboard.example.com")
+
+		mux.ServeHTTP(w, r)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		for header, want := range map[string]string{
+			"access-control-allow-origin":      "https://dashboard.example.com",
+			"access-control-expose-headers":    "ETag, X-Request-ID",
+			"access-control-allow-credentials": "",
+			"vary":                             "Origin",
+		} {
+			if got := w.Header().Get(header); got != want {
+				t.Errorf("got %s %q, want %q", header, got, want)
+			}
+		}
+	})
+
+	t.Run("Disallowed origin", func(t *testing.T) {
+		mux := newMux(WithCORS(cors))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+		r.Header.Set("origin", "https://evil.example.com")
+
+		mux.ServeHTTP(w, r)
+
+		if got := w.Header().Get("access-control-allow-origin"); got != "" {
+			t.Errorf("got access-control-allow-origin %q, want none", got)
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		authn := authenticatorFunc(func(string) (auth.Principal, error) {
+			return auth.Principal{}, auth.ErrUnauthenticated
+		})
+
+		for _, tc := range []struct {
+			desc     string
+			origin   string
+			method   string
+			headers  string
+			wantCode int
+		}{
+			{"Allowed", "https://dashboard.example.com", http.MethodGet, "X-API-Key", http.StatusNoContent},
+			{"Allowed method", "https://dashboard.example.com", http.MethodDelete, "", http.StatusNoContent},
+			{"Disallowed origin", "https://evil.example.com", http.MethodGet, "", http.StatusForbidden},
+			{"Disallowed method", "https://dashboard.example.com", http.MethodPut, "", http.StatusForbidden},
+			{"Disallowed header", "https://dashboard.example.com", http.MethodGet, "X-Foo", http.StatusForbidden},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				mux := newMux(WithCORS(cors), WithAuth(authn))
+
+				w := httptest.NewRecorder()
+				r := httptest.NewRequest(http.MethodOptions, "/top-fat-characters", nil)
+				r.Header.Set("origin", tc.origin)
+				r.Header.Set("access-control-request-method", tc.method)
+				if tc.headers != "" {
+					r.Header.Set("access-control-request-headers", tc.headers)
+				}
+
+				mux.ServeHTTP(w, r)
+
+				if got, want := w.Code, tc.wantCode; got != want {
+					t.Fatalf("got HTTP %d, want %d", got, want)
+				}
+
+				if w.Code != http.StatusNoContent {
+					return
+				}
+
+				for header, want := range map[string]string{
+					"access-control-allow-origin":  tc.origin,
+					"access-control-allow-methods": tc.method,
+					"access-control-allow-headers": tc.headers,
+					"access-control-max-age":       "600",
+				} {
+					if got := w.Header().Get(header); got != want {
+						t.Errorf("got %s %q, want %q", header, got, want)
+					}
+				}
+			})
+		}
+	})
+
+	t.Run("Credentials", func(t *testing.T) {
+		cors := cors
+		cors.AllowCredentials = true
+
+		mux := newMux(WithCORS(cors))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+		r.Header.Set("origin", "https://dashboard.example.com")
+
+		mux.ServeHTTP(w, r)
+
+		if got, want := w.Header().Get("access-control-allow-origin"), "https://dashboard.example.com"; got != want {
+			t.Errorf("got access-control-allow-origin %q, want %q", got, want)
+		}
+
+		if got, want := w.Header().Get("access-control-allow-credentials"), "true"; got != want {
+			t.Errorf("got access-control-allow-credentials %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Credentials from any origin", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("did not panic")
+			}
+		}()
+
+		cors := cors
+		cors.AllowedOrigins = []string{"*"}
+		cors.AllowCredentials = true
+
+		WithCORS(cors)
+	})
+
+	t.Run("Any origin", func(t *testing.T) {
+		cors := cors
+		cors.AllowedOrigins = []string{"*"}
+
+		mux := newMux(WithCORS(cors))
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+		r.Header.Set("origin", "https://dashboard.example.com")
+
+		mux.ServeHTTP(w, r)
+
+		if got, want := w.Header().Get("access-control-allow-origin"), "*"; got != want {
+			t.Errorf("got access-control-allow-origin %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		mux := newMux()
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/top-fat-characters", nil)
+		r.Header.Set("origin", "https://dashboard.example.com")
+
+		mux.ServeHTTP(w, r)
+
+		if got := w.Header().Get("access-control-allow-origin"); got != "" {
+			t.Errorf("got access-control-allow-origin %q, want none", got)
+		}
+	})
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// computedFields are the fields computed from characters, included in
+// responses only when requested with the fields query parameter.
+var computedFields = []string{"bmi", "bmi_category", "age_bby"}
+
+// schemaFields are the fields of starwars.Character in JSON.
+var schemaFields = jsonFields(reflect.TypeOf(starwars.Character{}))
+
+// responseFields are the fields of characters in responses, in order.
+var responseFields = jsonFields(reflect.TypeOf(character{}))
+
+// unitFields are the fields annotating the units of fields.
+var unitFields = map[string]string{
+	"height": "height_unit",
+	"mass":   "mass_unit",
+}
+
+// jsonFields returns the names of the fields of the struct type t in JSON,
+// including those of embedded structs.
+func jsonFields(t reflect.Type) []string {
+	var fields []string
+
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+
+		fields = append(fields, name)
+	}
+
+	return fields
+}
+
+// fieldSelection is the selection of fields with the fields query parameter,
+// a comma-separated list of fields. If any fields are selected, responses are
+// projected to exactly those fields; otherwise they have every field of
+// starwars.Character and no computed fields.
+type fieldSelection struct {
+	computed []string // computed fields to include
+	only     []string // fields to project to, nil for all
+}
+
+// requestedFields returns the fields selected by the fields query parameter of
+// r. If a field is unknown, it is returned with ok=false.
+func requestedFields(r *http.Request) (s fieldSelection, unknown string, ok bool) {
+	for _, field := range strings.Split(r.URL.Query().Get("fields"), ",") {
+		field = strings.TrimSpace(field)
+
+		switch {
+		case field == "":
+			continue
+		case slices.Contains(computedFields, field):
+			s.computed = append(s.computed, field)
+		case !slices.Contains(schemaFields, field):
+			return fieldSelection{}, field, false
+		}
+
+		s.only = append(s.only, field)
+
+		if unitField, ok := unitFields[field]; ok {
+			s.only = append(s.only, unitField)
+		}
+	}
+
+	return s, "", true
+}
+
+// validFields returns the fields that may be selected.
+func validFields() []string {
+	return append(slices.Clone(schemaFields), computedFields...)
+}
+
+// project returns the characters projected to the fields of s.
+func (s fieldSelection) project(cs []character) (any, error) {
+	projected := make([]projection, len(cs))
+
+	for i, c := range cs {
+		b, err := json.Marshal(c)
+		if err != nil {
+			return nil, err
+		}
+
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(b, &values); err != nil {
+			return nil, err
+		}
+
+		for _, field := range responseFields {
+			if v, ok := values[field]; ok && slices.Contains(s.only, field) {
+				projected[i] = append(projected[i], projectedField{field, v})
+			}
+		}
+	}
+
+	return projected, nil
+}
+
+// projection is a JSON object of selected fields, in order.
+type projection []projectedField
+
+type projectedField struct {
+	name  string
+	value json.RawMessage
+}
+
+func (p projection) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+
+	for i, f := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		name, err := json.Marshal(f.name)
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(f.value)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// addComputedFields sets the given computed fields of dto, computed from c in
+// canonical units. Fields that cannot be computed, e.g. the BMI of a character
+// of unknown mass, are left out.
+func addComputedFields(dto *character, c starwars.Character, fields []string) {
+	bmi, hasBMI := c.BMI()
+	age, hasAge := c.AgeBBY()
+
+	for _, field := range fields {
+		switch field {
+		case "bmi":
+			if hasBMI {
+				v := math.Round(bmi*100) / 100
+				dto.BMI = &v
+			}
+		case "bmi_category":
+			if hasBMI {
+				dto.BMICategory = bmiCategory(bmi)
+			}
+		case "age_bby":
+			if hasAge {
+				dto.AgeBBY = &age
+			}
+		}
+	}
+}
+
+// bmiCategory returns the WHO weight category of the given BMI.
+func bmiCategory(bmi float64) string {
+	switch {
+	case bmi < 18.5:
+		return "underweight"
+	case bmi < 25:
+		return "normal"
+	case bmi < 30:
+		return "overweight"
+	default:
+		return "obese"
+	}
+}
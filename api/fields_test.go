@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_ComputedFields(t *testing.T) {
+	_, get := newTestMux(t, []starwars.Character{
+		{Name: "Luke Skywalker", Height: 172, Mass: 77, BirthYear: "19BBY"},
+		{Name: "Arvel Crynyd"},
+	})
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{
+			"/v1/top-fat-characters",
+			`[{"name":"Luke Skywalker","height":"172","mass":"77","birth_year":"19BBY"},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			"/v1/top-fat-characters?fields=bmi,bmi_category,age_bby",
+			`[{"bmi":26.03,"bmi_category":"overweight","age_bby":19},{}]`,
+		},
+		{
+			"/v1/top-fat-characters?fields=name,age_bby",
+			`[{"name":"Luke Skywalker","age_bby":19},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			// Computed from canonical units
+			"/v2/top-fat-characters?fields=height,mass,bmi&units=imperial",
+			`{"data":[{"height":"67.7","mass":"169.8","height_unit":"in","mass_unit":"lb","bmi":26.03},{}],"meta":{"count":2,"generation":1,"units":"imperial"}}`,
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			w := get(tc.path, nil)
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Unknown field", func(t *testing.T) {
+		w := get("/v1/top-fat-characters?fields=bmi,foo", nil)
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := strings.TrimSpace(w.Body.String()), `unknown field "foo", valid fields are: name, height, mass, birth_year, hair_color, skin_color, eye_color, gender, homeworld, films, species, starships, vehicles, url, bmi, bmi_category, age_bby`; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+	})
+}
+
+func TestAPI_SparseFieldsets(t *testing.T) {
+	_, get := newTestMux(t, []starwars.Character{
+		{Name: "Luke Skywalker", Height: 172, Mass: 77, BirthYear: "19BBY", Films: []string{"https://swapi.dev/api/films/1/"}},
+		{Name: "Arvel Crynyd"},
+	})
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{
+			"/v1/top-fat-characters?fields=name",
+			`[{"name":"Luke Skywalker"},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			// In the order of the schema
+			"/v1/top-old-characters?fields=films,%20name",
+			`[{"name":"Luke Skywalker","films":["https://swapi.dev/api/films/1/"]},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			"/v1/top-fat-characters?fields=name,bmi",
+			`[{"name":"Luke Skywalker","bmi":26.03},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			// Units follow their fields
+			"/v2/top-fat-characters?fields=name,mass&units=imperial",
+			`{"data":[{"name":"Luke Skywalker","mass":"169.8","mass_unit":"lb"},{"name":"Arvel Crynyd"}],"meta":{"count":2,"generation":1,"units":"imperial"}}`,
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			w := get(tc.path, nil)
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Unknown field", func(t *testing.T) {
+		w := get("/v2/top-old-characters?fields=name,height_unit", nil)
+
+		if got, want := w.Code, http.StatusBadRequest; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := w.Body.String(), `unknown field "height_unit"`; !strings.Contains(got, want) {
+			t.Errorf("got body %q, want it to contain %q", got, want)
+		}
+	})
+}
+
+func TestBMICategory(t *testing.T) {
+	for _, tc := range []struct {
+		bmi  float64
+		want string
+	}{
+		{15, "underweight"},
+		{18.5, "normal"},
+		{24.9, "normal"},
+		{25, "overweight"},
+		{30, "obese"},
+		{443.43, "obese"},
+	} {
+		if got := bmiCategory(tc.bmi); got != tc.want {
+			t.Errorf("bmiCategory(%g) = %s, want %s", tc.bmi, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// healthz reports that the process is alive and serving requests.
+func (a *API) healthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httpError(w, r, http.StatusMethodNotAllowed, "error_method_not_allowed")
+		return
+	}
+
+	w.Header().Set("cache-control", "no-store")
+	w.Header().Set("content-type", "application/json")
+
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+type readiness struct {
+	Ready    bool           `json:"ready"`
+	Cache    cacheReadiness `json:"cache"`
+	Upstream upstream       `json:"upstream"`
+}
+
+type cacheReadiness struct {
+	Populated     bool    `json:"populated"`
+	Generation    uint64  `json:"generation"`
+	AgeSeconds    float64 `json:"age_seconds"`
+	LastError     string  `json:"last_error,omitempty"`
+	LastRefreshed string  `json:"last_refreshed,omitempty"`
+}
+
+type upstream struct {
+	Reachable bool   `json:"reachable"`
+	Error     string `json:"error,omitempty"`
+}
+
+// readyz reports whether the service is ready to serve traffic, which is when
+// the character cache has been populated and SWAPI is reachable within the
+// upstream budget.
+func (a *API) readyz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		httpError(w, r, http.StatusMethodNotAllowed, "error_method_not_allowed")
+		return
+	}
+
+	status := a.core.CacheStatus()
+
+	var res readiness
+
+	res.Cache.Populated = !status.Updated.IsZero()
+	res.Cache.Generation = status.Generation
+	res.Cache.AgeSeconds = status.Age(time.Now()).Seconds()
+	res.Cache.LastError = status.LastError
+
+	if !status.Updated.IsZero() {
+		res.Cache.LastRefreshed = status.Updated.UTC().Format(time.RFC3339)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), a.upstreamBudget)
+	defer cancel()
+
+	if err := a.core.Ping(ctx); err != nil {
+		res.Upstream.Error = err.Error()
+	} else {
+		res.Upstream.Reachable = true
+	}
+
+	res.Ready = res.Cache.Populated && res.Upstream.Reachable
+
+	w.Header().Set("cache-control", "no-store")
+	w.Header().Set("content-type", "application/json")
+
+	if !res.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	json.NewEncoder(w).Encode(&res)
+}
+
+// gated wraps h so that it responds with 503 Service Unavailable until the
+// character cache has been populated, if the readiness gate is enabled.
+func (a *API) gated(h http.HandlerFunc) http.HandlerFunc {
+	if !a.readinessGate {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.core.CacheStatus().Generation == 0 {
+			w.Header().Set("retry-after", "5")
+			httpError(w, r, http.StatusServiceUnavailable, "error_warming_up")
+			return
+		}
+
+		h(w, r)
+	}
+}
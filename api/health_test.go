@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Healthz(t *testing.T) {
+	a := New(nil)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+
+	a.healthz(w, r)
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("got HTTP %d, want %d", got, want)
+	}
+
+	if got, want := w.Body.String(), `{"status":"ok"}`+"\n"; got != want {
+		t.Errorf("got body:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestAPI_Readyz(t *testing.T) {
+	updated := time.Now().Add(-time.Minute)
+
+	for _, tc := range []struct {
+		desc      string
+		status    starwars.CacheStatus
+		pingErr   error
+		wantCode  int
+		wantReady bool
+	}{
+		{
+			desc:      "Ready",
+			status:    starwars.CacheStatus{Generation: 1, Updated: updated},
+			wantCode:  http.StatusOK,
+			wantReady: true,
+		},
+		{
+			desc:     "Cold cache",
+			status:   starwars.CacheStatus{LastError: "foo error"},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc:     "Purged cache",
+			status:   starwars.CacheStatus{Generation: 1},
+			wantCode: http.StatusServiceUnavailable,
+		},
+		{
+			desc:     "Upstream unreachable",
+			status:   starwars.CacheStatus{Generation: 1, Updated: updated},
+			pingErr:  errors.New("foo error"),
+			wantCode: http.StatusServiceUnavailable,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			a := New(
+				&mock.Core{
+					CacheStatusFunc: func() starwars.CacheStatus {
+						return tc.status
+					},
+					PingFunc: func(ctx context.Context) error {
+						return tc.pingErr
+					},
+				},
+			)
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+			a.readyz(w, r)
+
+			if got, want := w.Code, tc.wantCode; got != want {
+				t.Errorf("got HTTP %d, want %d", got, want)
+			}
+
+			var res readiness
+
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatalf("error decoding body: %v", err)
+			}
+
+			if got, want := res.Ready, tc.wantReady; got != want {
+				t.Errorf("ready is %t, want %t", got, want)
+			}
+
+			if got, want := res.Cache.LastError, tc.status.LastError; got != want {
+				t.Errorf("cache last error is %q, want %q", got, want)
+			}
+
+			if !tc.status.Updated.IsZero() && res.Cache.AgeSeconds < 60 {
+				t.Errorf("cache age is %f seconds, want at least 60", res.Cache.AgeSeconds)
+			}
+
+			if got, want := res.Upstream.Reachable, tc.pingErr == nil; got != want {
+				t.Errorf("upstream reachable is %t, want %t", got, want)
+			}
+		})
+	}
+
+	t.Run("Upstream budget", func(t *testing.T) {
+		a := New(
+			&mock.Core{
+				CacheStatusFunc: func() starwars.CacheStatus {
+					return starwars.CacheStatus{Generation: 1, Updated: updated}
+				},
+				PingFunc: func(ctx context.Context) error {
+					<-ctx.Done()
+					return ctx.Err()
+				},
+			},
+			WithUpstreamBudget(10*time.Millisecond),
+		)
+
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+		a.readyz(w, r)
+
+		if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+	})
+}
+
+func TestAPI_Gated(t *testing.T) {
+	var generation uint64
+
+	a := New(
+		&mock.Core{
+			CacheStatusFunc: func() starwars.CacheStatus {
+				return starwars.CacheStatus{Generation: generation}
+			},
+		},
+		WithReadinessGate(),
+	)
+
+	h := a.gated(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foo"))
+	})
+
+	w := httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got, want := w.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("cold cache: got HTTP %d, want %d", got, want)
+	}
+
+	if got := w.Header().Get("retry-after"); got == "" {
+		t.Error("cold cache: retry-after is not set")
+	}
+
+	generation = 1
+
+	w = httptest.NewRecorder()
+	h(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("warm cache: got HTTP %d, want %d", got, want)
+	}
+}
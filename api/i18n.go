@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jsageryd/starwars-coding-test/i18n"
+)
+
+// langCookie remembers the language chosen with the lang query parameter, so
+// that it applies to the pages linked to.
+const langCookie = "lang"
+
+// locale returns the locale to respond to r in: that given by the lang query
+// parameter or cookie, or else the one best matching Accept-Language.
+func locale(r *http.Request) *i18n.Locale {
+	if l, ok := i18n.Lookup(r.URL.Query().Get("lang")); ok {
+		return l
+	}
+
+	if c, err := r.Cookie(langCookie); err == nil {
+		if l, ok := i18n.Lookup(c.Value); ok {
+			return l
+		}
+	}
+
+	return i18n.Negotiate(r.Header.Get("accept-language"))
+}
+
+// setLanguageHeaders sets the headers of a response localized to l, and
+// remembers a language chosen with the lang query parameter.
+func setLanguageHeaders(w http.ResponseWriter, r *http.Request, l *i18n.Locale) {
+	w.Header().Set("content-language", l.Tag)
+	vary(w, "Accept-Language", "Cookie")
+
+	if _, ok := i18n.Lookup(r.URL.Query().Get("lang")); ok {
+		http.SetCookie(w, &http.Cookie{
+			Name:     langCookie,
+			Value:    l.Tag,
+			Path:     "/",
+			MaxAge:   365 * 24 * 60 * 60,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// httpError replies to r with the message with the given key, localized, and
+// the given HTTP status code.
+func httpError(w http.ResponseWriter, r *http.Request, code int, key string, args ...any) {
+	l := locale(r)
+	setLanguageHeaders(w, r, l)
+	http.Error(w, l.T(key, args...), code)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_I18n(t *testing.T) {
+	c, get := newTestMux(t, []starwars.Character{
+		{Name: "Jabba Desilijic Tiure", Height: 175, Mass: 1358, BirthYear: "600BBY"},
+	})
+
+	c.CharacterFunc = func(ctx context.Context, name string) (starwars.Character, error) {
+		return starwars.Character{}, starwars.ErrNotFound
+	}
+	c.CacheStatusFunc = func() starwars.CacheStatus {
+		return starwars.CacheStatus{}
+	}
+
+	for _, tc := range []struct {
+		name   string
+		path   string
+		header map[string]string
+		lang   string
+		want   []string
+	}{
+		{
+			name: "Fallback",
+			path: "/",
+			lang: "en",
+			want: []string{`<html lang="en">`, "<h1>Star Wars character rankings</h1>", `<td class="number" data-value="1358">1,358</td>`},
+		},
+		{
+			name:   "Accept-Language",
+			path:   "/",
+			header: map[string]string{"accept-language": "fr, de;q=0.8"},
+			lang:   "de",
+			want:   []string{`<html lang="de">`, "<h1>Ranglisten der Star-Wars-Figuren</h1>", `<td class="number" data-value="1358">1.358</td>`},
+		},
+		{
+			name:   "Query parameter",
+			path:   "/?lang=sv",
+			header: map[string]string{"accept-language": "de"},
+			lang:   "sv",
+			want:   []string{`<html lang="sv">`, "De 1 fetaste Star Wars-figurerna efter BMI", `<a href="?lang=sv" lang="sv" aria-current="true">Svenska</a>`},
+		},
+		{
+			name:   "Cookie",
+			path:   "/",
+			header: map[string]string{"cookie": "lang=sv", "accept-language": "de"},
+			lang:   "sv",
+			want:   []string{"<h1>Topplistor över Star Wars-figurer</h1>"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := get(tc.path, tc.header)
+
+			if got, want := w.Header().Get("content-language"), tc.lang; got != want {
+				t.Errorf("got content-language %q, want %q", got, want)
+			}
+
+			if got, want := strings.Join(w.Header().Values("vary"), ", "), "Accept-Language, Cookie"; !strings.Contains(got, want) {
+				t.Errorf("got vary %q, want it to contain %q", got, want)
+			}
+
+			for _, want := range tc.want {
+				if !strings.Contains(w.Body.String(), want) {
+					t.Errorf("body does not contain %q:\n%s", want, w.Body.String())
+				}
+			}
+		})
+	}
+
+	t.Run("Remembered language", func(t *testing.T) {
+		if got, want := get("/?lang=de", nil).Header().Get("set-cookie"), "lang=de;"; !strings.HasPrefix(got, want) {
+			t.Errorf("got set-cookie %q, want prefix %q", got, want)
+		}
+
+		if got := get("/", nil).Header().Get("set-cookie"); got != "" {
+			t.Errorf("got set-cookie %q without lang parameter, want none", got)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		w := get("/characters/foo", map[string]string{"accept-language": "de"})
+
+		if got, want := w.Code, http.StatusNotFound; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := strings.TrimSpace(w.Body.String()), "404 Seite nicht gefunden"; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Entity tags", func(t *testing.T) {
+		c.CacheStatusFunc = func() starwars.CacheStatus {
+			return starwars.CacheStatus{Generation: 1, Updated: time.Now()}
+		}
+
+		en := get("/", nil).Header().Get("etag")
+		de := get("/?lang=de", nil).Header().Get("etag")
+		deHeader := get("/", map[string]string{"accept-language": "de"}).Header().Get("etag")
+
+		if en == deHeader {
+			t.Errorf("got the same etag %s for different languages", en)
+		}
+
+		if de == deHeader {
+			t.Errorf("got the same etag %s for different URLs", de)
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jsageryd/starwars-coding-test/logging"
+)
+
+// withRequestID wraps h so that each request carries a request ID in its
+// context, for correlating log lines. The ID is taken from the X-Request-ID
+// header if it is present and valid, or generated otherwise, and is echoed in
+// the response.
+func withRequestID(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("x-request-id")
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+		}
+
+		w.Header().Set("x-request-id", id)
+
+		h(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	}
+}
+
+// validRequestID reports whether id is safe to use as a request ID, so that
+// clients cannot inject arbitrary data into log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+
+	for _, r := range id {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+		case r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
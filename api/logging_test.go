@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/logging"
+)
+
+func TestWithRequestID(t *testing.T) {
+	for _, tc := range []struct {
+		desc     string
+		header   string
+		wantKept bool
+	}{
+		{"No header", "", false},
+		{"Valid header", "foo-123_bar.baz:1", true},
+		{"Invalid characters", "foo\nbar", false},
+		{"Too long", strings.Repeat("a", 129), false},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var gotCtxID string
+
+			h := withRequestID(func(w http.ResponseWriter, r *http.Request) {
+				gotCtxID = logging.RequestID(r.Context())
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tc.header != "" {
+				r.Header.Set("x-request-id", tc.header)
+			}
+
+			h(w, r)
+
+			gotHeaderID := w.Header().Get("x-request-id")
+
+			if gotHeaderID == "" {
+				t.Fatal("response has no request ID")
+			}
+
+			if got, want := gotCtxID, gotHeaderID; got != want {
+				t.Errorf("got request ID %q in context, want %q", got, want)
+			}
+
+			if got := gotHeaderID == tc.header; got != tc.wantKept {
+				t.Errorf("got request ID %q for header %q, want kept: %t", gotHeaderID, tc.header, tc.wantKept)
+			}
+		})
+	}
+}
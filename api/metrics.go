@@ -0,0 +1,82 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/metrics"
+)
+
+type apiMetrics struct {
+	requests *metrics.Counter
+	duration *metrics.Histogram
+}
+
+func newAPIMetrics(reg *metrics.Registry) *apiMetrics {
+	return &apiMetrics{
+		requests: reg.Counter(
+			"http_requests_total",
+			"Total number of HTTP requests by route and status code.",
+			"route", "code",
+		),
+		duration: reg.Histogram(
+			"http_request_duration_seconds",
+			"Duration of HTTP requests in seconds by route and status code.",
+			metrics.DefaultBuckets,
+			"route", "code",
+		),
+	}
+}
+
+// instrumented wraps h to record request counts and durations for route, and
+// to log each request.
+func (a *API) instrumented(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+
+		h(sw, r)
+
+		duration := time.Since(start)
+
+		code := strconv.Itoa(sw.code)
+
+		a.metrics.requests.Inc(route, code)
+		a.metrics.duration.Observe(duration.Seconds(), route, code)
+
+		slog.InfoContext(r.Context(), "Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"route", route,
+			"status", sw.code,
+			"duration", duration,
+		)
+	}
+}
+
+// statusWriter records the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.wroteHeader = true
+		sw.code = code
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(b []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(b)
+}
+
+func (sw *statusWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
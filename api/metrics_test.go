@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/metrics"
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Metrics(t *testing.T) {
+	mux := http.NewServeMux()
+
+	New(
+		&mock.Core{
+			TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+				return []starwars.Character{{Name: "R2-D2", Height: 96, Mass: 32}}, nil
+			},
+			TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+				return nil, errors.New("foo error")
+			},
+			CacheStatusFunc: func() starwars.CacheStatus {
+				return starwars.CacheStatus{}
+			},
+		},
+		WithMetrics(metrics.NewRegistry()),
+	).Register(mux)
+
+	for _, path := range []string{
+		"/top-fat-characters",
+		"/top-fat-characters",
+		"/top-old-characters",
+	} {
+		mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Fatalf("got HTTP %d, want %d", got, want)
+	}
+
+	for _, wantLine := range []string{
+		`http_requests_total{route="/top-fat-characters",code="200"} 2`,
+		`http_requests_total{route="/top-old-characters",code="500"} 1`,
+		`http_request_duration_seconds_count{route="/top-fat-characters",code="200"} 2`,
+	} {
+		if !strings.Contains(w.Body.String(), wantLine+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", wantLine, w.Body.String())
+		}
+	}
+}
+
+func TestAPI_MetricsNotEnabled(t *testing.T) {
+	mux := http.NewServeMux()
+
+	New(
+		&mock.Core{
+			TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+				return nil, errors.New("foo error")
+			},
+			CacheStatusFunc: func() starwars.CacheStatus {
+				return starwars.CacheStatus{}
+			},
+		},
+	).Register(mux)
+
+	w := httptest.NewRecorder()
+
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := w.Body.String(); strings.Contains(got, "http_requests_total") {
+		t.Errorf("got metrics without enabling them:\n%s", got)
+	}
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// characterURL returns the path of the profile page of the named character.
+func characterURL(name string) string {
+	return "/characters/" + url.PathEscape(name)
+}
+
+// entityURL returns the path of the page of the entity of the given kind with
+// the given SWAPI URL.
+func entityURL(kind, swapiURL string) string {
+	return "/" + kind + "/" + url.PathEscape(starwars.ID(swapiURL))
+}
+
+// references returns the SWAPI URLs of the entities of the given kind that c
+// refers to.
+func references(c starwars.Character, kind string) []string {
+	switch kind {
+	case starwars.Films:
+		return c.Films
+	case starwars.Planets:
+		if c.Homeworld != "" {
+			return []string{c.Homeworld}
+		}
+	case starwars.Species:
+		return c.Species
+	case starwars.Starships:
+		return c.Starships
+	case starwars.Vehicles:
+		return c.Vehicles
+	}
+	return nil
+}
+
+type link struct {
+	Name string
+	URL  string
+}
+
+// related returns links to the pages of the entities of the given kind with
+// the given SWAPI URLs. If the entities cannot be fetched, the links are named
+// after their identifiers, and if they are not available at all, e.g. when
+// serving offline data, they lead nowhere.
+func (a *API) related(ctx context.Context, kind string, urls []string) []link {
+	if len(urls) == 0 {
+		return nil
+	}
+
+	names := make(map[string]string)
+
+	entities, err := a.core.Entities(ctx, kind)
+	unavailable := errors.Is(err, starwars.ErrUnavailable)
+	if err != nil && !unavailable {
+		slog.WarnContext(ctx, "Error fetching related entities", "kind", kind, "error", err)
+	}
+
+	for _, e := range entities {
+		names[e.URL] = e.Name
+	}
+
+	links := make([]link, len(urls))
+
+	for i, u := range urls {
+		name := names[u]
+		if name == "" {
+			name = "#" + starwars.ID(u)
+		}
+		links[i] = link{Name: name}
+		if !unavailable {
+			links[i].URL = entityURL(kind, u)
+		}
+	}
+
+	return links
+}
+
+// profile renders the profile page of the character named by the path, with
+// links to the pages of related entities.
+func (a *API) profile(w http.ResponseWriter, r *http.Request) {
+	name, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/characters/"))
+	if err != nil || name == "" {
+		httpError(w, r, http.StatusNotFound, "error_not_found")
+		return
+	}
+
+	c, err := a.core.Character(r.Context(), name)
+	if errors.Is(err, starwars.ErrNotFound) {
+		httpError(w, r, http.StatusNotFound, "error_not_found")
+		return
+	}
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "error_unknown")
+		slog.ErrorContext(r.Context(), "Error fetching character", "error", err)
+		return
+	}
+
+	data := struct {
+		starwars.Character
+		BMI       float64
+		HasBMI    bool
+		Age       float64 // years between birth and the Battle of Yavin
+		HasAge    bool
+		BornAfter bool // born after the Battle of Yavin
+		Homeworld []link
+		Species   []link
+		Films     []link
+		Starships []link
+		Vehicles  []link
+	}{
+		Character: c,
+		Homeworld: a.related(r.Context(), starwars.Planets, references(c, starwars.Planets)),
+		Species:   a.related(r.Context(), starwars.Species, c.Species),
+		Films:     a.related(r.Context(), starwars.Films, c.Films),
+		Starships: a.related(r.Context(), starwars.Starships, c.Starships),
+		Vehicles:  a.related(r.Context(), starwars.Vehicles, c.Vehicles),
+	}
+
+	data.BMI, data.HasBMI = c.BMI()
+	data.Age, data.HasAge = c.AgeBBY()
+
+	if data.Age < 0 {
+		data.Age, data.BornAfter = -data.Age, true
+	}
+
+	render(w, r, "profile", data)
+}
+
+// entity returns a handler rendering the page of the entity of the given kind
+// identified by the path, listing the characters that refer to it.
+func (a *API) entity(kind string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/"+kind+"/")
+
+		entities, err := a.core.Entities(r.Context(), kind)
+		if errors.Is(err, starwars.ErrUnavailable) {
+			httpError(w, r, http.StatusNotFound, "error_unavailable")
+			return
+		}
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "error_unknown")
+			slog.ErrorContext(r.Context(), "Error fetching entities", "kind", kind, "error", err)
+			return
+		}
+
+		i := slices.IndexFunc(entities, func(e starwars.Entity) bool {
+			return id != "" && starwars.ID(e.URL) == id
+		})
+		if i < 0 {
+			httpError(w, r, http.StatusNotFound, "error_not_found")
+			return
+		}
+
+		e := entities[i]
+
+		characters, err := a.core.Characters(r.Context())
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "error_unknown")
+			slog.ErrorContext(r.Context(), "Error fetching characters", "error", err)
+			return
+		}
+
+		var links []link
+
+		for _, c := range characters {
+			if slices.Contains(references(c, kind), e.URL) {
+				links = append(links, link{Name: c.Name, URL: characterURL(c.Name)})
+			}
+		}
+
+		type attribute struct {
+			Label string
+			Value string
+		}
+
+		attributes := make([]attribute, len(e.Attributes))
+
+		for i, attr := range e.Attributes {
+			attributes[i] = attribute{Label: strings.ReplaceAll(attr.Key, "_", " "), Value: attr.Value}
+		}
+
+		data := struct {
+			Kind       string
+			Name       string
+			Attributes []attribute
+			Characters []link
+		}{
+			Kind:       kind,
+			Name:       e.Name,
+			Attributes: attributes,
+			Characters: links,
+		}
+
+		render(w, r, "entity", data)
+	}
+}
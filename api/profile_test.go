@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/core"
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+	"github.com/jsageryd/starwars-coding-test/swapi"
+)
+
+func profileCore() *mock.Core {
+	luke := starwars.Character{
+		Name:      "Luke Skywalker",
+		Height:    172,
+		Mass:      77,
+		BirthYear: "19BBY",
+		Gender:    "male",
+		Homeworld: "https://swapi.dev/api/planets/1/",
+		Films:     []string{"https://swapi.dev/api/films/1/", "https://swapi.dev/api/films/2/"},
+		Starships: []string{"https://swapi.dev/api/starships/12/"},
+	}
+
+	leia := starwars.Character{
+		Name:      "Leia Organa",
+		Homeworld: "https://swapi.dev/api/planets/2/",
+		Films:     []string{"https://swapi.dev/api/films/1/"},
+	}
+
+	return &mock.Core{
+		CharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{luke, leia}, nil
+		},
+		CharacterFunc: func(ctx context.Context, name string) (starwars.Character, error) {
+			if strings.EqualFold(name, luke.Name) {
+				return luke, nil
+			}
+			return starwars.Character{}, starwars.ErrNotFound
+		},
+		EntitiesFunc: func(ctx context.Context, kind string) ([]starwars.Entity, error) {
+			switch kind {
+			case starwars.Films:
+				return []starwars.Entity{
+					{URL: "https://swapi.dev/api/films/1/", Name: "A New Hope", Attributes: []starwars.Attribute{{Key: "release_date", Value: "1977-05-25"}}},
+					{URL: "https://swapi.dev/api/films/2/", Name: "The Empire Strikes Back"},
+				}, nil
+			case starwars.Planets:
+				return []starwars.Entity{{URL: "https://swapi.dev/api/planets/1/", Name: "Tatooine"}}, nil
+			}
+			return nil, errors.New("foo error")
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{}
+		},
+	}
+}
+
+func TestAPI_Profile(t *testing.T) {
+	mux := http.NewServeMux()
+	New(profileCore()).Register(mux)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/characters/Luke%20Skywalker", nil))
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		body := w.Body.String()
+
+		for _, want := range []string{
+			`<h1>Luke Skywalker</h1>`,
+			`<dd>172 cm</dd>`,
+			`<dd>26.0</dd>`,
+			`<dd>19 years at the Battle of Yavin</dd>`,
+			`<dd>male</dd>`,
+			`<a href="/planets/1">Tatooine</a>`,
+			`<a href="/films/1">A New Hope</a>`,
+			`<a href="/films/2">The Empire Strikes Back</a>`,
+			`<a href="/starships/12">#12</a>`, // starships cannot be fetched
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body does not contain %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/characters/Jar%20Jar%20Binks", nil))
+
+		if got, want := w.Code, http.StatusNotFound; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Linked from rankings", func(t *testing.T) {
+		c := profileCore()
+		c.TopFatCharactersFunc = c.CharactersFunc
+		c.TopOldCharactersFunc = c.CharactersFunc
+
+		mux := http.NewServeMux()
+		New(c).Register(mux)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if want := `<a href="/characters/Luke%20Skywalker">Luke Skywalker</a>`; !strings.Contains(w.Body.String(), want) {
+			t.Errorf("body does not contain %q:\n%s", want, w.Body.String())
+		}
+	})
+}
+
+func TestAPI_Entity(t *testing.T) {
+	mux := http.NewServeMux()
+	New(profileCore()).Register(mux)
+
+	t.Run("Success", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/films/1", nil))
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		body := w.Body.String()
+
+		for _, want := range []string{
+			`<h1>A New Hope</h1>`,
+			`<dt>release date</dt>`,
+			`<dd>1977-05-25</dd>`,
+			`<a href="/characters/Luke%20Skywalker">Luke Skywalker</a>`,
+			`<a href="/characters/Leia%20Organa">Leia Organa</a>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body does not contain %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		for _, path := range []string{"/films/3", "/films/"} {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+			if got, want := w.Code, http.StatusNotFound; got != want {
+				t.Errorf("got HTTP %d for %s, want %d", got, path, want)
+			}
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/starships/12", nil))
+
+		if got, want := w.Code, http.StatusInternalServerError; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		c := core.New(swapi.NewClient("http://localhost:0", swapi.WithOfflineData([]starwars.Character{
+			{Name: "Luke Skywalker", Homeworld: "https://swapi.dev/api/planets/1/"},
+		})))
+
+		mux := http.NewServeMux()
+		New(c).Register(mux)
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/planets/1", nil))
+
+		if got, want := w.Code, http.StatusNotFound; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := strings.TrimSpace(w.Body.String()), "This page is not available when serving a snapshot"; got != want {
+			t.Errorf("got body %q, want %q", got, want)
+		}
+
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/characters/Luke%20Skywalker", nil))
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d for profile, want %d", got, want)
+		}
+
+		body := w.Body.String()
+
+		if want := `<li>#1</li>`; !strings.Contains(body, want) {
+			t.Errorf("profile does not contain %q:\n%s", want, body)
+		}
+
+		if unwanted := `href="/planets/`; strings.Contains(body, unwanted) {
+			t.Errorf("profile links to unavailable page:\n%s", body)
+		}
+	})
+}
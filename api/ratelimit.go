@@ -0,0 +1,200 @@
+package api
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+)
+
+// RateLimit is a token bucket limit on requests from a single client. Rate
+// tokens are added per second, up to Burst.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// WithRateLimits limits the requests each client may make, per route. Clients
+// are identified as authenticated, if using WithAuth, and by IP address
+// otherwise. The limit for the route "*" is a single limit on the requests to
+// all routes without limits of their own, except the probes /healthz and
+// /readyz, which it does not apply to. Requests over the limit are rejected
+// with 429 Too Many Requests.
+func WithRateLimits(limits map[string]RateLimit) Option {
+	return func(a *API) {
+		a.rateLimits = limits
+		a.defaultLimiter = nil
+
+		if limit, ok := limits["*"]; ok && limit.Rate > 0 {
+			a.defaultLimiter = newLimiter(limit)
+		}
+	}
+}
+
+// probes are the routes of health checks, which the limit for "*" does not
+// apply to, so that a busy client cannot get the service restarted.
+var probes = []string{"/healthz", "/readyz"}
+
+// rateLimited enforces the rate limit for route, if any, on h.
+func (a *API) rateLimited(route string, h http.HandlerFunc) http.HandlerFunc {
+	var l *limiter
+
+	if limit, ok := a.rateLimits[route]; ok {
+		if limit.Rate > 0 {
+			l = newLimiter(limit)
+		}
+	} else if !slices.Contains(probes, route) {
+		l = a.defaultLimiter
+	}
+
+	if l == nil {
+		return h
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		ok, remaining, reset, retryAfter := l.allow(clientKey(r))
+
+		w.Header().Set("ratelimit-limit", strconv.Itoa(l.limit.Burst))
+		w.Header().Set("ratelimit-remaining", strconv.Itoa(remaining))
+		w.Header().Set("ratelimit-reset", strconv.Itoa(seconds(reset)))
+
+		if !ok {
+			w.Header().Set("retry-after", strconv.Itoa(seconds(retryAfter)))
+			httpError(w, r, http.StatusTooManyRequests, "error_rate_limited")
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+// clientKey identifies the client making r.
+func clientKey(r *http.Request) string {
+	if p, ok := auth.PrincipalFromContext(r.Context()); ok {
+		return "principal:" + p.ID
+	}
+
+	return "ip:" + clientIP(r)
+}
+
+// clientIP returns the IP address of the client making r.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	return host
+}
+
+// seconds returns d in whole seconds, rounded up.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// limiter keeps a token bucket per client.
+type limiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func newLimiter(limit RateLimit) *limiter {
+	if limit.Burst < 1 {
+		limit.Burst = 1
+	}
+
+	return &limiter{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// allow takes a token from the bucket of key, if there is one. It returns
+// whether there was, the number of tokens left, the time until the bucket is
+// full and, if there was no token, the time until there is one.
+func (l *limiter) allow(key string) (ok bool, remaining int, reset, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(key)
+
+	burst := float64(l.limit.Burst)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		retryAfter = l.duration(1 - b.tokens)
+	}
+
+	return ok, int(b.tokens), l.duration(burst - b.tokens), retryAfter
+}
+
+// wait returns the time until the bucket of key has a token, or 0 if it has
+// one, without taking it.
+func (l *limiter) wait(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b := l.bucket(key); b.tokens < 1 {
+		return l.duration(1 - b.tokens)
+	}
+
+	return 0
+}
+
+// bucket returns the bucket of key, refilled until now. l.mu must be held.
+func (l *limiter) bucket(key string) *bucket {
+	now := l.now()
+
+	l.sweep(now)
+
+	burst := float64(l.limit.Burst)
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+
+	return b
+}
+
+// sweep forgets buckets that have been refilled, at most once a minute, so
+// that clients that have gone away do not use memory forever.
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.duration(float64(l.limit.Burst)-b.tokens) <= now.Sub(b.last) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// duration returns the time it takes to add the given number of tokens.
+func (l *limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.limit.Rate * float64(time.Second))
+}
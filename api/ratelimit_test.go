@@ -0,0 +1,209 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+)
+
+func TestAPI_RateLimited(t *testing.T) {
+	a := New(nil, WithRateLimits(map[string]RateLimit{
+		"*":    {Rate: 1, Burst: 2},
+		"/foo": {Rate: 0.5, Burst: 1},
+	}))
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	request := func(h http.HandlerFunc, remoteAddr, principal string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = remoteAddr
+		if principal != "" {
+			r = r.WithContext(auth.WithPrincipal(r.Context(), auth.Principal{ID: principal}))
+		}
+		h(w, r)
+		return w
+	}
+
+	t.Run("Default limit", func(t *testing.T) {
+		h := a.rateLimited("/bar", ok)
+
+		for n, want := range []struct {
+			code      int
+			remaining string
+		}{
+			{http.StatusOK, "1"},
+			{http.StatusOK, "0"},
+			{http.StatusTooManyRequests, "0"},
+		} {
+			w := request(h, "192.0.2.1:1234", "")
+
+			if got, want := w.Code, want.code; got != want {
+				t.Errorf("[%d] got status %d, want %d", n, got, want)
+			}
+
+			if got, want := w.Header().Get("ratelimit-limit"), "2"; got != want {
+				t.Errorf("[%d] got ratelimit-limit %q, want %q", n, got, want)
+			}
+
+			if got, want := w.Header().Get("ratelimit-remaining"), want.remaining; got != want {
+				t.Errorf("[%d] got ratelimit-remaining %q, want %q", n, got, want)
+			}
+
+			if w.Code == http.StatusTooManyRequests {
+				if got, want := w.Header().Get("retry-after"), "1"; got != want {
+					t.Errorf("[%d] got retry-after %q, want %q", n, got, want)
+				}
+
+				if got, want := w.Header().Get("ratelimit-reset"), "2"; got != want {
+					t.Errorf("[%d] got ratelimit-reset %q, want %q", n, got, want)
+				}
+			}
+		}
+
+		if got, want := request(h, "192.0.2.2:1234", "").Code, http.StatusOK; got != want {
+			t.Errorf("got status %d for other IP, want %d", got, want)
+		}
+
+		if got, want := request(h, "192.0.2.1:1234", "foo").Code, http.StatusOK; got != want {
+			t.Errorf("got status %d for principal, want %d", got, want)
+		}
+	})
+
+	t.Run("Route limit", func(t *testing.T) {
+		h := a.rateLimited("/foo", ok)
+
+		if got, want := request(h, "192.0.2.1:1234", "").Code, http.StatusOK; got != want {
+			t.Errorf("got status %d, want %d", got, want)
+		}
+
+		w := request(h, "192.0.2.1:1234", "")
+
+		if got, want := w.Code, http.StatusTooManyRequests; got != want {
+			t.Errorf("got status %d, want %d", got, want)
+		}
+
+		if got, want := w.Header().Get("retry-after"), "2"; got != want {
+			t.Errorf("got retry-after %q, want %q", got, want)
+		}
+	})
+
+	t.Run("No limit", func(t *testing.T) {
+		a := New(nil, WithRateLimits(map[string]RateLimit{"/foo": {Rate: 1, Burst: 1}}))
+
+		h := a.rateLimited("/bar", ok)
+
+		for n := 0; n < 3; n++ {
+			w := request(h, "192.0.2.1:1234", "")
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Errorf("[%d] got status %d, want %d", n, got, want)
+			}
+
+			if got := w.Header().Get("ratelimit-limit"); got != "" {
+				t.Errorf("[%d] got ratelimit-limit %q, want none", n, got)
+			}
+		}
+	})
+
+	t.Run("Default limit shared by routes", func(t *testing.T) {
+		a := New(nil, WithRateLimits(map[string]RateLimit{
+			"*":    {Rate: 1, Burst: 2},
+			"/foo": {Rate: 1, Burst: 1},
+		}))
+
+		bar, baz, foo := a.rateLimited("/bar", ok), a.rateLimited("/baz", ok), a.rateLimited("/foo", ok)
+
+		for n, want := range []struct {
+			h    http.HandlerFunc
+			code int
+		}{
+			{bar, http.StatusOK},
+			{baz, http.StatusOK},
+			{bar, http.StatusTooManyRequests},
+			{baz, http.StatusTooManyRequests},
+			{foo, http.StatusOK},
+		} {
+			if got, want := request(want.h, "192.0.2.1:1234", "").Code, want.code; got != want {
+				t.Errorf("[%d] got status %d, want %d", n, got, want)
+			}
+		}
+	})
+
+	t.Run("Probes", func(t *testing.T) {
+		a := New(nil, WithRateLimits(map[string]RateLimit{
+			"*":       {Rate: 1, Burst: 1},
+			"/readyz": {Rate: 1, Burst: 1},
+		}))
+
+		for _, tc := range []struct {
+			route     string
+			wantCodes string
+		}{
+			{"/healthz", "[200 200 200]"},
+			{"/readyz", "[200 429 429]"},
+		} {
+			h := a.rateLimited(tc.route, ok)
+
+			var gotCodes []int
+			for n := 0; n < 3; n++ {
+				gotCodes = append(gotCodes, request(h, "192.0.2.1:1234", "").Code)
+			}
+
+			if got, want := fmt.Sprint(gotCodes), tc.wantCodes; got != want {
+				t.Errorf("%s: got statuses %s, want %s", tc.route, got, want)
+			}
+		}
+	})
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2023, 6, 13, 9, 0, 0, 0, time.UTC)
+
+	l := newLimiter(RateLimit{Rate: 2, Burst: 4})
+	l.now = func() time.Time { return now }
+
+	for n := 0; n < 4; n++ {
+		if ok, _, _, _ := l.allow("foo"); !ok {
+			t.Fatalf("[%d] request not allowed", n)
+		}
+	}
+
+	ok, remaining, reset, retryAfter := l.allow("foo")
+
+	if ok {
+		t.Error("request allowed over burst")
+	}
+
+	if got, want := remaining, 0; got != want {
+		t.Errorf("got remaining %d, want %d", got, want)
+	}
+
+	if got, want := reset, 2*time.Second; got != want {
+		t.Errorf("got reset %v, want %v", got, want)
+	}
+
+	if got, want := retryAfter, 500*time.Millisecond; got != want {
+		t.Errorf("got retry after %v, want %v", got, want)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+
+	if ok, _, _, _ := l.allow("foo"); !ok {
+		t.Error("request not allowed after refill")
+	}
+
+	t.Run("Sweep", func(t *testing.T) {
+		now = now.Add(time.Hour)
+
+		l.allow("bar")
+
+		if got, want := len(l.buckets), 1; got != want {
+			t.Errorf("got %d buckets, want %d", got, want)
+		}
+	})
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// search renders the characters matching the q query parameter as HTML, most
+// relevant first.
+func (a *API) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	var characters []starwars.Character
+
+	if query != "" {
+		var err error
+
+		characters, err = a.core.Search(r.Context(), query)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "error_unknown")
+			slog.ErrorContext(r.Context(), "Error searching characters", "error", err)
+			return
+		}
+	}
+
+	data := struct {
+		Query      string
+		Characters []starwars.Character
+	}{
+		Query:      query,
+		Characters: characters,
+	}
+
+	render(w, r, "search", data)
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/search"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Search(t *testing.T) {
+	index := search.NewIndex([]starwars.Character{
+		{Name: "Obi-Wan Kenobi", Height: 182, Mass: 77, BirthYear: "57BBY"},
+		{Name: "Chewbacca", Height: 228, Mass: 112, BirthYear: "200BBY"},
+		{Name: "<b>Bold</b>"},
+	})
+
+	var gotQueries []string
+
+	c, get := newTestMux(t, nil)
+
+	c.SearchFunc = func(ctx context.Context, query string) ([]starwars.Character, error) {
+		gotQueries = append(gotQueries, query)
+
+		if query == "fail" {
+			return nil, errors.New("foo error")
+		}
+
+		var characters []starwars.Character
+		for _, r := range index.Search(query, 0) {
+			characters = append(characters, r.Character)
+		}
+		return characters, nil
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		w := get("/search?q=Chewie", nil)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		body := w.Body.String()
+
+		for _, want := range []string{
+			`<title>Results for “Chewie”</title>`,
+			`<input type="search" name="q" value="Chewie"`,
+			`<td><a href="/characters/Chewbacca">Chewbacca</a></td>`,
+			`<td class="number">228</td>`,
+			`<a href="?lang=sv&amp;q=Chewie" lang="sv">Svenska</a>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body does not contain %s", want)
+			}
+		}
+
+		if strings.Contains(body, "Obi-Wan") {
+			t.Error("body contains non-matching character")
+		}
+	})
+
+	t.Run("No results", func(t *testing.T) {
+		body := get("/search?q=Yoda", nil).Body.String()
+
+		if want := `No characters match “Yoda”.`; !strings.Contains(body, want) {
+			t.Errorf("body does not contain %s", want)
+		}
+	})
+
+	t.Run("No query", func(t *testing.T) {
+		gotQueries = nil
+
+		w := get("/search?q=%20", nil)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := len(gotQueries), 0; got != want {
+			t.Errorf("searched %d times, want %d", got, want)
+		}
+
+		if want := `Search by name or appearance`; !strings.Contains(w.Body.String(), want) {
+			t.Errorf("body does not contain %s", want)
+		}
+	})
+
+	t.Run("Escaping", func(t *testing.T) {
+		body := get("/search?q=%3Cb%3Ebold", nil).Body.String()
+
+		if strings.Contains(body, "<b>") {
+			t.Errorf("body contains unescaped markup: %s", body)
+		}
+
+		if want := `&lt;b&gt;Bold&lt;/b&gt;`; !strings.Contains(body, want) {
+			t.Errorf("body does not contain %s", want)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		if got, want := get("/search?q=fail", nil).Code, http.StatusInternalServerError; got != want {
+			t.Errorf("got HTTP %d, want %d", got, want)
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		for _, tc := range []struct {
+			path string
+			want string
+		}{
+			{
+				"/v1/search?q=obi+wan",
+				`[{"name":"Obi-Wan Kenobi","height":"182","mass":"77","birth_year":"57BBY"}]`,
+			},
+			{
+				"/v2/search?q=kenobe&fields=name",
+				`{"data":[{"name":"Obi-Wan Kenobi"}],"meta":{"count":1,"generation":1}}`,
+			},
+			{
+				"/v1/search?q=yoda",
+				`[]`,
+			},
+		} {
+			t.Run(tc.path, func(t *testing.T) {
+				w := get(tc.path, nil)
+
+				if got, want := w.Code, http.StatusOK; got != want {
+					t.Fatalf("got HTTP %d, want %d", got, want)
+				}
+
+				if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+					t.Errorf("got %s, want %s", got, tc.want)
+				}
+			})
+		}
+	})
+}
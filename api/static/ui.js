@@ -0,0 +1,114 @@
+// Sorting, searching and limiting of the ranking tables. The page works
+// without this script; it only adds interactivity.
+"use strict";
+
+(function () {
+  // sortValue returns the value a cell sorts by. Birth years are ordered on
+  // the BBY/ABY scale, so that 896BBY sorts before 19BBY. Localized numbers
+  // sort by their data-value.
+  function sortValue(cell, type) {
+    var text = cell.dataset.value || cell.textContent.trim();
+
+    switch (type) {
+      case "number":
+        var n = parseFloat(text);
+        return isNaN(n) ? -Infinity : n;
+      case "birthyear":
+        var m = /^([0-9.]+)(BBY|ABY)$/.exec(text);
+        if (!m) {
+          return Infinity;
+        }
+        return m[2] === "BBY" ? -parseFloat(m[1]) : parseFloat(m[1]);
+      default:
+        return text.toLowerCase();
+    }
+  }
+
+  function makeSortable(table) {
+    var headers = table.querySelectorAll("th[data-sort]");
+
+    headers.forEach(function (th, column) {
+      var button = document.createElement("button");
+      button.type = "button";
+      button.textContent = th.textContent;
+      th.textContent = "";
+      th.appendChild(button);
+
+      button.addEventListener("click", function () {
+        var ascending = th.getAttribute("aria-sort") !== "ascending";
+
+        headers.forEach(function (other) {
+          other.removeAttribute("aria-sort");
+        });
+        th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+        var tbody = table.tBodies[0];
+        var rows = Array.prototype.slice.call(tbody.rows);
+        var type = th.dataset.sort;
+
+        rows.sort(function (a, b) {
+          var x = sortValue(a.cells[column], type);
+          var y = sortValue(b.cells[column], type);
+          var order = x < y ? -1 : x > y ? 1 : 0;
+          return ascending ? order : -order;
+        });
+
+        rows.forEach(function (row) {
+          tbody.appendChild(row);
+        });
+
+        update();
+      });
+    });
+  }
+
+  var search = document.getElementById("search");
+  var limit = document.getElementById("limit");
+  var tables = document.querySelectorAll("table[data-ranking]");
+
+  // update shows the rows matching the search, within the rank limit. Ranks
+  // are fixed by the server, so the limit applies regardless of sorting.
+  function update() {
+    var query = search.value.trim().toLowerCase();
+    var n = parseInt(limit.value, 10);
+
+    tables.forEach(function (table) {
+      var shown = 0;
+
+      Array.prototype.forEach.call(table.tBodies[0].rows, function (row) {
+        var rank = parseInt(row.dataset.rank, 10);
+        var matches = row.dataset.name.toLowerCase().indexOf(query) !== -1;
+        row.hidden = !(matches && (isNaN(n) || rank <= n));
+        if (!row.hidden) {
+          shown++;
+        }
+      });
+
+      table.parentNode.querySelector(".empty").hidden = shown > 0;
+    });
+  }
+
+  tables.forEach(makeSortable);
+
+  search.addEventListener("input", update);
+
+  limit.addEventListener("change", function () {
+    var url = new URL(window.location.href);
+    if (limit.value) {
+      url.searchParams.set("n", limit.value);
+    } else {
+      url.searchParams.delete("n");
+    }
+    window.history.replaceState(null, "", url);
+    update();
+  });
+
+  // Without the script, the form reloads the page with the selected limit.
+  document.querySelector(".controls").addEventListener("submit", function (e) {
+    e.preventDefault();
+  });
+
+  search.hidden = false;
+
+  update();
+})();
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/jsageryd/starwars-coding-test/tracing"
+)
+
+// traced wraps h in a span for route, continuing the trace given by the
+// traceparent header of the request, if any.
+func (a *API) traced(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+
+		ctx, span := tracing.Start(ctx, r.Method+" "+route, tracing.KindServer,
+			tracing.Attr("http.method", r.Method),
+			tracing.Attr("http.route", route),
+			tracing.Attr("http.target", r.URL.RequestURI()),
+		)
+		defer span.End()
+
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+
+		h(sw, r.WithContext(ctx))
+
+		span.SetAttributes(tracing.Attr("http.status_code", sw.code))
+
+		if sw.code >= 500 {
+			span.RecordError(errorStatus(sw.code))
+		}
+	}
+}
+
+type errorStatus int
+
+func (e errorStatus) Error() string {
+	return http.StatusText(int(e))
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/tracing"
+)
+
+func TestAPI_Traced(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+
+	defer tracing.SetDefault(tracing.Default())
+	tracing.SetDefault(tracing.NewTracer(exporter))
+
+	var a API
+
+	t.Run("Success", func(t *testing.T) {
+		parent := "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+
+		var gotSpanContext tracing.SpanContext
+
+		h := a.traced("/foo", func(w http.ResponseWriter, r *http.Request) {
+			gotSpanContext = tracing.SpanContextFromContext(r.Context())
+		})
+
+		r := httptest.NewRequest(http.MethodGet, "/foo?bar=baz", nil)
+		r.Header.Set("traceparent", parent)
+
+		h(httptest.NewRecorder(), r)
+
+		spans := exporter.Spans()
+
+		if got, want := len(spans), 1; got != want {
+			t.Fatalf("got %d spans, want %d", got, want)
+		}
+
+		span := spans[0]
+
+		if got, want := span.Name, "GET /foo"; got != want {
+			t.Errorf("got name %q, want %q", got, want)
+		}
+
+		if got, want := span.Kind, tracing.KindServer; got != want {
+			t.Errorf("got kind %v, want %v", got, want)
+		}
+
+		if got, want := span.SpanContext.TraceID.String(), "0af7651916cd43dd8448eb211c80319c"; got != want {
+			t.Errorf("got trace ID %s, want %s", got, want)
+		}
+
+		if got, want := span.Parent.SpanID.String(), "b7ad6b7169203331"; got != want {
+			t.Errorf("got parent span ID %s, want %s", got, want)
+		}
+
+		if got, want := gotSpanContext, span.SpanContext; got != want {
+			t.Errorf("got span context %v in handler, want %v", got, want)
+		}
+
+		if got, want := attr(span, "http.status_code"), any(http.StatusOK); got != want {
+			t.Errorf("got status code %v, want %v", got, want)
+		}
+
+		if span.Err != "" {
+			t.Errorf("got error %q, want none", span.Err)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		h := a.traced("/foo", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+
+		h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/foo", nil))
+
+		spans := exporter.Spans()
+		span := spans[len(spans)-1]
+
+		if span.Parent.IsValid() {
+			t.Errorf("got parent %v, want root span", span.Parent)
+		}
+
+		if got, want := attr(span, "http.status_code"), any(http.StatusInternalServerError); got != want {
+			t.Errorf("got status code %v, want %v", got, want)
+		}
+
+		if got, want := span.Err, "Internal Server Error"; got != want {
+			t.Errorf("got error %q, want %q", got, want)
+		}
+	})
+}
+
+func attr(span tracing.SpanData, key string) any {
+	for _, a := range span.Attributes {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
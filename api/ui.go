@@ -0,0 +1,286 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"html/template"
+	"io/fs"
+	"log"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jsageryd/starwars-coding-test/i18n"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+	"github.com/jsageryd/starwars-coding-test/tracing"
+)
+
+//go:embed templates
+var templateFiles embed.FS
+
+//go:embed static
+var staticFiles embed.FS
+
+// assetVersions holds a short content hash of each static asset, used to bust
+// caches when an asset changes.
+var assetVersions = make(map[string]string)
+
+// contentSecurityPolicy restricts pages to the embedded styles and scripts,
+// so that markup in upstream data that escaped escaping still cannot run.
+const contentSecurityPolicy = "default-src 'none'; script-src 'self'; style-src 'self'; img-src 'self'; form-action 'self'; base-uri 'none'; frame-ancestors 'none'"
+
+// pages holds the template of each page, named after its file in
+// templates/pages. Pages define the title, header and content of the layout,
+// and may use the partials.
+var pages = make(map[string]*template.Template)
+
+func init() {
+	err := fs.WalkDir(staticFiles, "static", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		b, err := staticFiles.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(b)
+		assetVersions[path.Base(name)] = hex.EncodeToString(sum[:4])
+
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("Error reading static assets: %v", err)
+	}
+
+	if err := parsePages(); err != nil {
+		log.Fatalf("Error parsing templates: %v", err)
+	}
+}
+
+func parsePages() error {
+	funcs := template.FuncMap{
+		"asset":     assetURL,
+		"character": characterURL,
+		"chart":     figure,
+		"locales":   i18n.Locales,
+	}
+
+	fallback, _ := i18n.Lookup(i18n.Fallback)
+
+	for name, f := range pageFuncs(fallback, metric, nil) {
+		funcs[name] = f
+	}
+
+	layout, err := template.New("").Funcs(funcs).ParseFS(templateFiles, "templates/layout.tmpl", "templates/partials/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(templateFiles, "templates/pages/*.tmpl")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		page, err := layout.Clone()
+		if err != nil {
+			return err
+		}
+
+		if page, err = page.ParseFS(templateFiles, name); err != nil {
+			return err
+		}
+
+		pages[strings.TrimSuffix(path.Base(name), ".tmpl")] = page
+	}
+
+	return nil
+}
+
+// pageFuncs returns the template functions localizing pages to l, presenting
+// them in units u and linking to them with query q:
+//
+//	t key args...   the message with the given key formatted with args
+//	number v [n]    v with n decimals, or as few as needed
+//	lang            the locale
+//	height cm       the height in units
+//	mass kg         the mass in units
+//	units           the units
+//	unitSystems     all systems of units
+//	query key v     the query with key set to v, for links to the page
+func pageFuncs(l *i18n.Locale, u unitSystem, q url.Values) template.FuncMap {
+	return template.FuncMap{
+		"t": l.T,
+		"number": func(v float64, decimals ...int) string {
+			if len(decimals) > 0 {
+				return l.Number(v, decimals[0])
+			}
+			return l.Number(v, -1)
+		},
+		"lang": func() *i18n.Locale {
+			return l
+		},
+		"height": u.Height,
+		"mass":   u.Mass,
+		"units": func() unitSystem {
+			return u
+		},
+		"unitSystems": func() []unitSystem {
+			return unitSystems
+		},
+		"query": func(key, v string) string {
+			q := maps.Clone(q)
+			if q == nil {
+				q = make(url.Values)
+			}
+			q.Set(key, v)
+			return "?" + q.Encode()
+		},
+	}
+}
+
+// assetURL returns the URL of the named static asset, versioned by its
+// content so that it can be cached indefinitely.
+func assetURL(name string) string {
+	v, ok := assetVersions[name]
+	if !ok {
+		panic("unknown asset " + name)
+	}
+	return "/static/" + name + "?v=" + v
+}
+
+// static serves the embedded static assets. Assets requested with their
+// current version may be cached indefinitely.
+func (a *API) static(w http.ResponseWriter, r *http.Request) {
+	if strings.HasSuffix(r.URL.Path, "/") {
+		httpError(w, r, http.StatusNotFound, "error_not_found")
+		return
+	}
+
+	if v := r.URL.Query().Get("v"); v != "" && v == assetVersions[path.Base(r.URL.Path)] {
+		w.Header().Set("cache-control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("cache-control", "no-cache")
+	}
+
+	http.FileServer(http.FS(staticFiles)).ServeHTTP(w, r)
+}
+
+// rankingLimits are the choices of how many characters to show in the UI.
+var rankingLimits = []int{5, 10, 20, 50}
+
+type rankedCharacter struct {
+	starwars.Character
+	Rank   int
+	Hidden bool // beyond the number of characters to show
+}
+
+func ranked(cs []starwars.Character, n int) []rankedCharacter {
+	rcs := make([]rankedCharacter, len(cs))
+
+	for i, c := range cs {
+		rcs[i] = rankedCharacter{
+			Character: c,
+			Rank:      i + 1,
+			Hidden:    n > 0 && i >= n,
+		}
+	}
+
+	return rcs
+}
+
+// ui renders the rankings as HTML. The n query parameter sets how many
+// characters to show initially, all if absent.
+func (a *API) ui(w http.ResponseWriter, r *http.Request) {
+	fattestCharacters, err := a.core.TopFatCharacters(r.Context())
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "error_unknown")
+		slog.ErrorContext(r.Context(), "Error fetching characters", "error", err)
+		return
+	}
+
+	oldestCharacters, err := a.core.TopOldCharacters(r.Context())
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "error_unknown")
+		slog.ErrorContext(r.Context(), "Error fetching characters", "error", err)
+		return
+	}
+
+	n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+	if n < 0 {
+		n = 0
+	}
+
+	total := max(len(fattestCharacters), len(oldestCharacters))
+
+	var limits []int
+	for _, limit := range rankingLimits {
+		if limit < total {
+			limits = append(limits, limit)
+		}
+	}
+
+	if n > 0 && !slices.Contains(limits, n) {
+		limits = append(limits, n)
+		slices.Sort(limits)
+	}
+
+	data := struct {
+		FattestCharacters []rankedCharacter
+		OldestCharacters  []rankedCharacter
+		Limits            []int
+		N                 int // 0 for all
+	}{
+		FattestCharacters: ranked(fattestCharacters, n),
+		OldestCharacters:  ranked(oldestCharacters, n),
+		Limits:            limits,
+		N:                 n,
+	}
+
+	render(w, r, "index", data)
+}
+
+// render executes the named page with data, localized to the locale of r, and
+// writes the result as an HTML page.
+func render(w http.ResponseWriter, r *http.Request, name string, data any) {
+	var buf bytes.Buffer
+
+	l := locale(r)
+
+	u, _, err := requestedUnits(r, true)
+	if err != nil {
+		httpError(w, r, http.StatusBadRequest, "error_invalid_units")
+		return
+	}
+
+	_, span := tracing.Start(r.Context(), "render "+name, tracing.KindInternal, tracing.Attr("locale", l.Tag))
+	page, err := pages[name].Clone()
+	if err == nil {
+		err = page.Funcs(pageFuncs(l, u, r.URL.Query())).ExecuteTemplate(&buf, "layout", data)
+	}
+	span.RecordError(err)
+	span.End()
+
+	if err != nil {
+		httpError(w, r, http.StatusInternalServerError, "error_rendering_page")
+		slog.ErrorContext(r.Context(), "Error rendering page", "error", err)
+		return
+	}
+
+	setLanguageHeaders(w, r, l)
+	rememberUnits(w, r, u)
+	w.Header().Set("content-type", "text/html; charset=utf-8")
+	w.Header().Set("content-security-policy", contentSecurityPolicy)
+	w.Header().Set("x-content-type-options", "nosniff")
+
+	buf.WriteTo(w)
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_UI(t *testing.T) {
+	c, get := newTestMux(t, []starwars.Character{
+		{Name: "Jabba Desilijic Tiure", Height: 175, Mass: 1358},
+		{Name: "Dud Bolt", Height: 94, Mass: 45},
+		{Name: "Yoda", Height: 66, Mass: 17},
+	})
+
+	c.TopOldCharactersFunc = func(ctx context.Context) ([]starwars.Character, error) {
+		return []starwars.Character{
+			{Name: "Yoda", BirthYear: "896BBY"},
+			{Name: "Jabba Desilijic Tiure", BirthYear: "600BBY"},
+		}, nil
+	}
+	c.CacheStatusFunc = func() starwars.CacheStatus {
+		return starwars.CacheStatus{}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		w := get("/", nil)
+
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Fatalf("got HTTP %d, want %d", got, want)
+		}
+
+		if got, want := w.Header().Get("content-type"), "text/html; charset=utf-8"; got != want {
+			t.Errorf("got content-type %q, want %q", got, want)
+		}
+
+		body := w.Body.String()
+
+		for _, want := range []string{
+			`<tr data-rank="1" data-name="Jabba Desilijic Tiure">`,
+			`<tr data-rank="3" data-name="Yoda">`,
+			`<td>896BBY</td>`,
+			`<th data-sort="birthyear">Birth year</th>`,
+			`<option value="" selected>all</option>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body does not contain %q:\n%s", want, body)
+			}
+		}
+
+		if regexp.MustCompile(`<tr [^>]* hidden>`).MatchString(body) {
+			t.Error("rows hidden without a limit")
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		body := get("/?n=2", nil).Body.String()
+
+		for _, want := range []string{
+			`<tr data-rank="2" data-name="Dud Bolt">`,
+			`<tr data-rank="3" data-name="Yoda" hidden>`,
+			`<option value="2" selected>2</option>`,
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body does not contain %q:\n%s", want, body)
+			}
+		}
+	})
+
+	t.Run("Assets", func(t *testing.T) {
+		body := get("/", nil).Body.String()
+
+		urls := regexp.MustCompile(`(?:href|src)="(/static/[^"]+)"`).FindAllStringSubmatch(body, -1)
+
+		if got, want := len(urls), 2; got != want {
+			t.Fatalf("got %d asset URLs, want %d", got, want)
+		}
+
+		for _, url := range urls {
+			w := get(url[1], nil)
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Errorf("got HTTP %d for %s, want %d", got, url[1], want)
+			}
+
+			if got, want := w.Header().Get("cache-control"), "public, max-age=31536000, immutable"; got != want {
+				t.Errorf("got cache-control %q for %s, want %q", got, url[1], want)
+			}
+		}
+
+		if got, want := get("/static/ui.css", nil).Header().Get("cache-control"), "no-cache"; got != want {
+			t.Errorf("got cache-control %q for unversioned asset, want %q", got, want)
+		}
+
+		if got, want := get("/static/", nil).Code, http.StatusNotFound; got != want {
+			t.Errorf("got HTTP %d for directory, want %d", got, want)
+		}
+	})
+}
+
+func TestAPI_UI_Escaping(t *testing.T) {
+	const hostile = `"><script>alert(1)</script><img src=x onerror=alert(2)>`
+
+	character := starwars.Character{
+		Name:      hostile,
+		Height:    172,
+		Mass:      77,
+		BirthYear: "19BBY",
+		Gender:    hostile,
+		Homeworld: "javascript:alert(3)",
+	}
+
+	c := &mock.Core{
+		TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{character}, nil
+		},
+		TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{character}, nil
+		},
+		CharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{character}, nil
+		},
+		CharacterFunc: func(ctx context.Context, name string) (starwars.Character, error) {
+			return character, nil
+		},
+		EntitiesFunc: func(ctx context.Context, kind string) ([]starwars.Entity, error) {
+			return []starwars.Entity{{
+				URL:        "javascript:alert(3)",
+				Name:       hostile,
+				Attributes: []starwars.Attribute{{Key: hostile, Value: hostile}},
+			}}, nil
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{}
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(c).Register(mux)
+
+	for _, path := range []string{
+		"/",
+		"/characters/foo",
+		"/planets/javascript:alert(3)",
+	} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			if got, want := w.Header().Get("content-security-policy"), contentSecurityPolicy; got != want {
+				t.Errorf("got content-security-policy %q, want %q", got, want)
+			}
+
+			body := w.Body.String()
+
+			for _, unwanted := range []string{
+				"<script>alert",
+				"<img src=x",
+				`href="javascript:`,
+			} {
+				if strings.Contains(body, unwanted) {
+					t.Errorf("body contains %q:\n%s", unwanted, body)
+				}
+			}
+
+			if want := "&lt;script&gt;alert(1)&lt;/script&gt;"; !strings.Contains(body, want) {
+				t.Errorf("body does not contain %q:\n%s", want, body)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/http"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// unitsCookie remembers the units chosen with the units query parameter in the
+// UI, so that they apply to the pages linked to.
+const unitsCookie = "units"
+
+// unitSystem presents heights and masses, which are kept in centimetres and
+// kilograms, in a system of units. All conversion happens here; rankings and
+// other computations use the canonical units.
+type unitSystem struct {
+	Name       string
+	HeightUnit string
+	MassUnit   string
+	height     func(cm float64) float64
+	mass       func(kg float64) float64
+}
+
+var (
+	metric = unitSystem{
+		Name:       "metric",
+		HeightUnit: "cm",
+		MassUnit:   "kg",
+		height:     func(cm float64) float64 { return cm },
+		mass:       func(kg float64) float64 { return kg },
+	}
+
+	imperial = unitSystem{
+		Name:       "imperial",
+		HeightUnit: "in",
+		MassUnit:   "lb",
+		height:     func(cm float64) float64 { return roundTenth(cm / 2.54) },
+		mass:       func(kg float64) float64 { return roundTenth(kg / 0.45359237) },
+	}
+
+	unitSystems = []unitSystem{metric, imperial}
+)
+
+var errInvalidUnits = errors.New("invalid units")
+
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// Height converts a height in centimetres to u.
+func (u unitSystem) Height(cm float64) float64 {
+	return u.height(cm)
+}
+
+// Mass converts a mass in kilograms to u.
+func (u unitSystem) Mass(kg float64) float64 {
+	return u.mass(kg)
+}
+
+// Character returns c with its height and mass converted to u.
+func (u unitSystem) Character(c starwars.Character) starwars.Character {
+	c.Height = u.Height(c.Height)
+	c.Mass = u.Mass(c.Mass)
+	return c
+}
+
+// requestedUnits returns the units given by the units query parameter of r,
+// and whether they were given; if not, units remembered by a cookie are used
+// if remembered is true, or else metric.
+func requestedUnits(r *http.Request, remembered bool) (u unitSystem, explicit bool, err error) {
+	name := r.URL.Query().Get("units")
+
+	if name == "" && remembered {
+		if c, err := r.Cookie(unitsCookie); err == nil {
+			name = c.Value
+		}
+	}
+
+	if name == "" {
+		return metric, false, nil
+	}
+
+	for _, u := range unitSystems {
+		if u.Name == name {
+			return u, true, nil
+		}
+	}
+
+	return metric, false, errInvalidUnits
+}
+
+// rememberUnits remembers units chosen with the units query parameter.
+func rememberUnits(w http.ResponseWriter, r *http.Request, u unitSystem) {
+	if r.URL.Query().Get("units") != u.Name {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     unitsCookie,
+		Value:    u.Name,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
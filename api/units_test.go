@@ -0,0 +1,135 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestUnitSystem_Character(t *testing.T) {
+	c := starwars.Character{Name: "Luke Skywalker", Height: 172, Mass: 77}
+
+	if got, want := metric.Character(c), c; got.Height != want.Height || got.Mass != want.Mass {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	got := imperial.Character(c)
+
+	if got, want := got.Height, 67.7; got != want {
+		t.Errorf("got height %g, want %g", got, want)
+	}
+
+	if got, want := got.Mass, 169.8; got != want {
+		t.Errorf("got mass %g, want %g", got, want)
+	}
+
+	if got, want := c.Height, 172.0; got != want {
+		t.Errorf("original height changed to %g, want %g", got, want)
+	}
+}
+
+func TestAPI_Units(t *testing.T) {
+	_, get := newTestMux(t, []starwars.Character{
+		{Name: "Luke Skywalker", Height: 172, Mass: 77, BirthYear: "19BBY"},
+		{Name: "Arvel Crynyd"},
+	})
+
+	for _, tc := range []struct {
+		path string
+		want string
+	}{
+		{
+			"/v1/top-fat-characters",
+			`[{"name":"Luke Skywalker","height":"172","mass":"77","birth_year":"19BBY"},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			"/v1/top-fat-characters?units=metric",
+			`[{"name":"Luke Skywalker","height":"172","mass":"77","birth_year":"19BBY","height_unit":"cm","mass_unit":"kg"},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			"/v1/top-fat-characters?units=imperial",
+			`[{"name":"Luke Skywalker","height":"67.7","mass":"169.8","birth_year":"19BBY","height_unit":"in","mass_unit":"lb"},{"name":"Arvel Crynyd"}]`,
+		},
+		{
+			"/v2/top-old-characters?units=imperial",
+			`{"data":[{"name":"Luke Skywalker","height":"67.7","mass":"169.8","birth_year":"19BBY","height_unit":"in","mass_unit":"lb"},{"name":"Arvel Crynyd"}],"meta":{"count":2,"generation":1,"units":"imperial"}}`,
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			w := get(tc.path, nil)
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			if got := strings.TrimSpace(w.Body.String()); got != tc.want {
+				t.Errorf("got %s, want %s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		for _, path := range []string{
+			"/v1/top-fat-characters?units=foo",
+			"/?units=foo",
+			"/charts/height-mass.svg?units=foo",
+		} {
+			w := get(path, nil)
+
+			if got, want := w.Code, http.StatusBadRequest; got != want {
+				t.Errorf("got HTTP %d for %s, want %d", got, path, want)
+			}
+
+			if got, want := strings.TrimSpace(w.Body.String()), "units must be metric or imperial"; got != want {
+				t.Errorf("got body %q for %s, want %q", got, path, want)
+			}
+		}
+	})
+
+	t.Run("UI", func(t *testing.T) {
+		w := get("/?units=imperial", nil)
+
+		if got, want := w.Header().Get("set-cookie"), "units=imperial;"; !strings.HasPrefix(got, want) {
+			t.Errorf("got set-cookie %q, want prefix %q", got, want)
+		}
+
+		for _, path := range []string{"/?units=imperial", "/"} {
+			body := get(path, map[string]string{"cookie": "units=imperial"}).Body.String()
+
+			for _, want := range []string{
+				`<th data-sort="number" class="number">Height (in)</th>`,
+				`<td class="number" data-value="172">67.7</td>`,
+				`<td class="number" data-value="77">169.8</td>`,
+				`<a href="?units=imperial" aria-current="true">Imperial</a>`,
+			} {
+				if !strings.Contains(body, want) {
+					t.Errorf("body for %s does not contain %q:\n%s", path, want, body)
+				}
+			}
+		}
+	})
+
+	t.Run("Cookie ignored by JSON endpoints", func(t *testing.T) {
+		body := get("/v1/top-fat-characters", map[string]string{"cookie": "units=imperial"}).Body.String()
+
+		if !strings.Contains(body, `"height":"172"`) {
+			t.Errorf("body is not in metric units: %s", body)
+		}
+	})
+
+	t.Run("Chart", func(t *testing.T) {
+		body := get("/charts/height-mass.svg?units=imperial", nil).Body.String()
+
+		for _, want := range []string{
+			"Height (in)",
+			"Mass (lb)",
+			"<title>Luke Skywalker (67.7 in, 169.8 lb)</title>",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("body does not contain %q:\n%s", want, body)
+			}
+		}
+	})
+}
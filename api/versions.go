@@ -0,0 +1,240 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// version is a version of the JSON API. Versions serve the same resources
+// under their own prefix, differing in how responses are enveloped and in
+// the fields of characters served by default.
+type version struct {
+	prefix   string
+	meta     bool                     // whether the envelope includes metadata
+	full     func(cs []character) any // characters as served unless projected with the fields query parameter
+	envelope func(data any, meta responseMeta) any
+}
+
+// responseMeta describes the data in a response, for versions that include it.
+type responseMeta struct {
+	Count      int    `json:"count"`
+	Generation uint64 `json:"generation"`      // cache generation the data is from
+	Units      string `json:"units,omitempty"` // units of heights and masses, if requested
+}
+
+// character is the JSON representation of a character, with its height and
+// mass in the requested units, annotated with the units if they were
+// requested, and with the computed fields requested.
+type character struct {
+	starwars.Character
+	HeightUnit  string   `json:"height_unit,omitempty"`
+	MassUnit    string   `json:"mass_unit,omitempty"`
+	BMI         *float64 `json:"bmi,omitempty"`
+	BMICategory string   `json:"bmi_category,omitempty"`
+	AgeBBY      *float64 `json:"age_bby,omitempty"` // age in years at the Battle of Yavin, negative if born after it
+}
+
+// characterV1 is the JSON representation of a character in v1, which keeps
+// the fields characters had when v1 was introduced. Other fields are served
+// in v1 only if selected with the fields query parameter.
+type characterV1 struct {
+	Name       string  `json:"name"`
+	Height     float64 `json:"height,string,omitempty"`
+	Mass       float64 `json:"mass,string,omitempty"`
+	BirthYear  string  `json:"birth_year,omitempty"`
+	HeightUnit string  `json:"height_unit,omitempty"`
+	MassUnit   string  `json:"mass_unit,omitempty"`
+}
+
+var (
+	v1 = version{
+		prefix: "/v1",
+		full: func(cs []character) any {
+			data := make([]characterV1, len(cs))
+			for i, c := range cs {
+				data[i] = characterV1{
+					Name:       c.Name,
+					Height:     c.Height,
+					Mass:       c.Mass,
+					BirthYear:  c.BirthYear,
+					HeightUnit: c.HeightUnit,
+					MassUnit:   c.MassUnit,
+				}
+			}
+			return data
+		},
+		envelope: func(data any, _ responseMeta) any {
+			return data
+		},
+	}
+
+	v2 = version{
+		prefix: "/v2",
+		meta:   true,
+		full: func(cs []character) any {
+			return cs
+		},
+		envelope: func(data any, meta responseMeta) any {
+			return struct {
+				Data any          `json:"data"`
+				Meta responseMeta `json:"meta"`
+			}{data, meta}
+		},
+	}
+
+	versions = []version{v1, v2}
+)
+
+// legacyVersion is the version served at the unversioned paths, which predate
+// versioning and are deprecated.
+var legacyVersion = v1
+
+// legacyDeprecated is when the unversioned paths were deprecated.
+var legacyDeprecated = time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+
+// WithLegacySunset announces, using the Sunset header, when the deprecated
+// unversioned paths will stop being served.
+func WithLegacySunset(t time.Time) Option {
+	return func(a *API) {
+		a.legacySunset = t
+	}
+}
+
+// resource is a JSON resource served by every version.
+type resource struct {
+	path   string
+	list   characterList
+	legacy bool // whether it is also served at its unversioned path
+}
+
+// characterList returns the characters requested by r from the core.
+type characterList func(c starwars.Core, r *http.Request) ([]starwars.Character, error)
+
+var resources = []resource{
+	{"/top-fat-characters", ranking(starwars.Core.TopFatCharacters), true},
+	{"/top-old-characters", ranking(starwars.Core.TopOldCharacters), true},
+	{"/search", searchResults, false},
+}
+
+// ranking returns a list of the characters of a ranking.
+func ranking(rank func(c starwars.Core, ctx context.Context) ([]starwars.Character, error)) characterList {
+	return func(c starwars.Core, r *http.Request) ([]starwars.Character, error) {
+		return rank(c, r.Context())
+	}
+}
+
+// searchResults returns the characters matching the q query parameter of r,
+// most relevant first.
+func searchResults(c starwars.Core, r *http.Request) ([]starwars.Character, error) {
+	return c.Search(r.Context(), r.URL.Query().Get("q"))
+}
+
+// registerVersions registers the resources of every version, and of the
+// legacy version at the unversioned paths of the resources predating
+// versioning.
+func (a *API) registerVersions(mux *http.ServeMux) {
+	for _, res := range resources {
+		for _, v := range versions {
+			a.handle(mux, v.prefix+res.path, a.authorized(auth.ScopeRead, a.gated(a.conditional(a.characters(v, res.list)))))
+		}
+
+		if !res.legacy {
+			continue
+		}
+
+		a.handle(mux, res.path, a.deprecated(legacyVersion.prefix+res.path, a.authorized(auth.ScopeRead, a.gated(a.conditional(a.characters(legacyVersion, res.list))))))
+	}
+}
+
+// characters serves the characters returned by list, enveloped as in v.
+func (a *API) characters(v version, list characterList) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("allow", "GET, HEAD")
+			httpError(w, r, http.StatusMethodNotAllowed, "error_method_not_allowed")
+			return
+		}
+
+		u, explicit, err := requestedUnits(r, false)
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error_invalid_units")
+			return
+		}
+
+		fields, unknown, ok := requestedFields(r)
+		if !ok {
+			httpError(w, r, http.StatusBadRequest, "error_unknown_field", unknown, strings.Join(validFields(), ", "))
+			return
+		}
+
+		characters, err := list(a.core, r)
+		if err != nil {
+			httpError(w, r, http.StatusInternalServerError, "error_unknown")
+			slog.ErrorContext(r.Context(), "Error fetching characters", "error", err)
+			return
+		}
+
+		data := make([]character, len(characters))
+
+		for i, c := range characters {
+			data[i] = character{Character: u.Character(c)}
+
+			if explicit && c.Height != 0 {
+				data[i].HeightUnit = u.HeightUnit
+			}
+
+			if explicit && c.Mass != 0 {
+				data[i].MassUnit = u.MassUnit
+			}
+
+			addComputedFields(&data[i], c, fields.computed)
+		}
+
+		projected := v.full(data)
+
+		if fields.only != nil {
+			if projected, err = fields.project(data); err != nil {
+				httpError(w, r, http.StatusInternalServerError, "error_unknown")
+				slog.ErrorContext(r.Context(), "Error projecting characters", "error", err)
+				return
+			}
+		}
+
+		w.Header().Set("content-type", "application/json")
+
+		var meta responseMeta
+
+		if v.meta {
+			meta.Count = len(characters)
+			meta.Generation = a.core.CacheStatus().Generation
+
+			if explicit {
+				meta.Units = u.Name
+			}
+		}
+
+		json.NewEncoder(w).Encode(v.envelope(projected, meta))
+	}
+}
+
+// deprecated marks the responses of h as deprecated in favour of successor.
+func (a *API) deprecated(successor string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("deprecation", "@"+strconv.FormatInt(legacyDeprecated.Unix(), 10))
+		w.Header().Set("link", "<"+successor+`>; rel="successor-version"`)
+
+		if !a.legacySunset.IsZero() {
+			w.Header().Set("sunset", a.legacySunset.UTC().Format(http.TimeFormat))
+		}
+
+		h(w, r)
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/mock"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestAPI_Versions(t *testing.T) {
+	c := &mock.Core{
+		TopFatCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{{
+				Name:      "R2-D2",
+				Height:    96,
+				Mass:      32,
+				SkinColor: "white, blue",
+				Films:     []string{"https://swapi.dev/api/films/1/"},
+				URL:       "https://swapi.dev/api/people/3/",
+			}}, nil
+		},
+		TopOldCharactersFunc: func(ctx context.Context) ([]starwars.Character, error) {
+			return []starwars.Character{{Name: "Yoda", BirthYear: "896BBY"}}, nil
+		},
+		CacheStatusFunc: func() starwars.CacheStatus {
+			return starwars.CacheStatus{Generation: 7}
+		},
+	}
+
+	mux := http.NewServeMux()
+	New(c, WithLegacySunset(time.Date(2027, 4, 19, 0, 0, 0, 0, time.UTC))).Register(mux)
+
+	for _, tc := range []struct {
+		path           string
+		wantBody       string
+		wantDeprecated bool
+	}{
+		{
+			path:     "/v1/top-fat-characters",
+			wantBody: `[{"name":"R2-D2","height":"96","mass":"32"}]`,
+		},
+		{
+			path:     "/v1/top-old-characters",
+			wantBody: `[{"name":"Yoda","birth_year":"896BBY"}]`,
+		},
+		{
+			path:     "/v1/top-fat-characters?fields=name,films",
+			wantBody: `[{"name":"R2-D2","films":["https://swapi.dev/api/films/1/"]}]`,
+		},
+		{
+			path:     "/v1/top-fat-characters?units=imperial",
+			wantBody: `[{"name":"R2-D2","height":"37.8","mass":"70.5","height_unit":"in","mass_unit":"lb"}]`,
+		},
+		{
+			path:     "/v2/top-fat-characters",
+			wantBody: `{"data":[{"name":"R2-D2","height":"96","mass":"32","skin_color":"white, blue","films":["https://swapi.dev/api/films/1/"],"url":"https://swapi.dev/api/people/3/"}],"meta":{"count":1,"generation":7}}`,
+		},
+		{
+			path:     "/v2/top-old-characters",
+			wantBody: `{"data":[{"name":"Yoda","birth_year":"896BBY"}],"meta":{"count":1,"generation":7}}`,
+		},
+		{
+			path:           "/top-fat-characters",
+			wantBody:       `[{"name":"R2-D2","height":"96","mass":"32"}]`,
+			wantDeprecated: true,
+		},
+		{
+			path:           "/top-old-characters",
+			wantBody:       `[{"name":"Yoda","birth_year":"896BBY"}]`,
+			wantDeprecated: true,
+		},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tc.path, nil)
+
+			mux.ServeHTTP(w, r)
+
+			if got, want := w.Code, http.StatusOK; got != want {
+				t.Fatalf("got HTTP %d, want %d", got, want)
+			}
+
+			if got, want := w.Body.String(), tc.wantBody+"\n"; got != want {
+				t.Errorf("got body:\n%s\nwant:\n%s", got, want)
+			}
+
+			wantHeaders := map[string]string{
+				"deprecation": "",
+				"sunset":      "",
+				"link":        "",
+			}
+
+			if tc.wantDeprecated {
+				wantHeaders = map[string]string{
+					"deprecation": "@1792368000",
+					"sunset":      "Mon, 19 Apr 2027 00:00:00 GMT",
+					"link":        `</v1` + tc.path + `>; rel="successor-version"`,
+				}
+			}
+
+			for header, want := range wantHeaders {
+				if got := w.Header().Get(header); got != want {
+					t.Errorf("got %s %q, want %q", header, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+// Package auth authenticates clients using static API keys or HMAC-signed
+// bearer tokens, and describes what they are allowed to do using scopes.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scopes granted to clients.
+const (
+	ScopeRead  = "read"  // read characters and rankings
+	ScopeAdmin = "admin" // operate the service, e.g. purge the cache; implies read
+)
+
+// ErrUnauthenticated is returned for missing, unknown, malformed or expired
+// credentials.
+var ErrUnauthenticated = errors.New("invalid credentials")
+
+// Principal is an authenticated client.
+type Principal struct {
+	ID     string   // key ID or token subject
+	Scopes []string // scopes granted
+}
+
+// HasScope reports whether p has been granted scope, either directly or by
+// the admin scope.
+func (p Principal) HasScope(scope string) bool {
+	return slices.Contains(p.Scopes, scope) || slices.Contains(p.Scopes, ScopeAdmin)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal carried by ctx, if any.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// KeysFile is the JSON file of API keys and the token secret.
+type KeysFile struct {
+	Keys []struct {
+		ID     string   `json:"id"`
+		Key    string   `json:"key"`
+		Scopes []string `json:"scopes"`
+	} `json:"keys"`
+	TokenSecret string `json:"token_secret"` // HMAC key for bearer tokens; tokens are rejected if empty
+}
+
+// Authenticator checks credentials against the keys in a keys file, which is
+// reloaded when it changes.
+type Authenticator struct {
+	path string
+
+	mu      sync.RWMutex
+	keys    map[[sha256.Size]byte]Principal // by hash of key, to not compare keys byte by byte
+	secret  []byte
+	modTime time.Time
+	size    int64
+}
+
+// NewFromFile returns an authenticator using the keys file at path.
+func NewFromFile(path string) (*Authenticator, error) {
+	a := &Authenticator{path: path}
+
+	if err := a.Reload(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Reload reads the keys file again. If it cannot be read or is invalid, the
+// previous keys are kept.
+func (a *Authenticator) Reload() error {
+	info, err := os.Stat(a.path)
+	if err != nil {
+		return fmt.Errorf("error reading keys file: %v", err)
+	}
+
+	b, err := os.ReadFile(a.path)
+	if err != nil {
+		return fmt.Errorf("error reading keys file: %v", err)
+	}
+
+	var f KeysFile
+
+	if err := json.Unmarshal(b, &f); err != nil {
+		return fmt.Errorf("error parsing keys file %s: %v", a.path, err)
+	}
+
+	keys := make(map[[sha256.Size]byte]Principal, len(f.Keys))
+
+	for n, k := range f.Keys {
+		if k.ID == "" || k.Key == "" {
+			return fmt.Errorf("key %d in keys file %s has no id or key", n, a.path)
+		}
+
+		for _, scope := range k.Scopes {
+			if scope != ScopeRead && scope != ScopeAdmin {
+				return fmt.Errorf("key %q in keys file %s has unknown scope %q", k.ID, a.path, scope)
+			}
+		}
+
+		hash := sha256.Sum256([]byte(k.Key))
+
+		if _, ok := keys[hash]; ok {
+			return fmt.Errorf("key %q in keys file %s is not unique", k.ID, a.path)
+		}
+
+		keys[hash] = Principal{ID: k.ID, Scopes: k.Scopes}
+	}
+
+	a.mu.Lock()
+	a.keys = keys
+	a.secret = []byte(f.TokenSecret)
+	a.modTime = info.ModTime()
+	a.size = info.Size()
+	a.mu.Unlock()
+
+	return nil
+}
+
+// Watch reloads the keys file whenever it has changed, checking at the given
+// interval until ctx is cancelled.
+func (a *Authenticator) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(a.path)
+		if err != nil {
+			slog.Error("Error checking keys file", "error", err)
+			continue
+		}
+
+		a.mu.RLock()
+		changed := !info.ModTime().Equal(a.modTime) || info.Size() != a.size
+		a.mu.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err := a.Reload(); err != nil {
+			slog.Error("Error reloading keys file, keeping previous keys", "error", err)
+			continue
+		}
+
+		slog.Info("Reloaded keys file", "path", a.path)
+	}
+}
+
+// Authenticate returns the principal identified by credential, which is
+// either an API key or a bearer token.
+func (a *Authenticator) Authenticate(credential string) (Principal, error) {
+	a.mu.RLock()
+	p, ok := a.keys[sha256.Sum256([]byte(credential))]
+	secret := a.secret
+	a.mu.RUnlock()
+
+	if ok {
+		return p, nil
+	}
+
+	if strings.Count(credential, ".") == 2 && len(secret) > 0 {
+		return VerifyToken(secret, credential, time.Now())
+	}
+
+	return Principal{}, ErrUnauthenticated
+}
+
+// Bearer tokens are JSON Web Tokens signed with HMAC-SHA256.
+
+type tokenHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+type tokenClaims struct {
+	Sub   string `json:"sub"`
+	Scope string `json:"scope"` // space-separated
+	Exp   int64  `json:"exp"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// SignToken returns a bearer token for p, valid until exp, signed with secret.
+func SignToken(secret []byte, p Principal, exp time.Time) (string, error) {
+	header, err := json.Marshal(tokenHeader{Alg: "HS256", Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(tokenClaims{
+		Sub:   p.ID,
+		Scope: strings.Join(p.Scopes, " "),
+		Exp:   exp.Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(claims)
+
+	return signed + "." + b64.EncodeToString(sign(secret, signed)), nil
+}
+
+// VerifyToken returns the principal of token if it is signed with secret and
+// has not expired at now.
+func VerifyToken(secret []byte, token string, now time.Time) (Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(sig, sign(secret, parts[0]+"."+parts[1])) {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	var header tokenHeader
+	if err := decodePart(parts[0], &header); err != nil || header.Alg != "HS256" {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	var claims tokenClaims
+	if err := decodePart(parts[1], &claims); err != nil || claims.Sub == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	if claims.Exp == 0 || !now.Before(time.Unix(claims.Exp, 0)) {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	return Principal{ID: claims.Sub, Scopes: strings.Fields(claims.Scope)}, nil
+}
+
+func sign(secret []byte, s string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
+
+func decodePart(s string, v any) error {
+	b, err := b64.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
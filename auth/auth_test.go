@@ -0,0 +1,187 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeKeysFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const keysFile = `
+{
+  "keys": [
+    {"id": "reader", "key": "foo", "scopes": ["read"]},
+    {"id": "operator", "key": "bar", "scopes": ["admin"]}
+  ],
+  "token_secret": "secret"
+}
+`
+
+func TestAuthenticator(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+
+	writeKeysFile(t, path, keysFile)
+
+	a, err := NewFromFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("API key", func(t *testing.T) {
+		p, err := a.Authenticate("foo")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(p), "{reader [read]}"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		token, err := SignToken([]byte("secret"), Principal{ID: "ci", Scopes: []string{"read"}}, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		p, err := a.Authenticate(token)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(p), "{ci [read]}"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Unknown key", func(t *testing.T) {
+		if _, err := a.Authenticate("baz"); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("error is %v, want %v", err, ErrUnauthenticated)
+		}
+	})
+
+	t.Run("Reload", func(t *testing.T) {
+		writeKeysFile(t, path, `{"keys": [{"id": "reader", "key": "baz", "scopes": ["read"]}]}`)
+
+		if err := a.Reload(); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := a.Authenticate("foo"); !errors.Is(err, ErrUnauthenticated) {
+			t.Errorf("error is %v for removed key, want %v", err, ErrUnauthenticated)
+		}
+
+		if _, err := a.Authenticate("baz"); err != nil {
+			t.Errorf("unexpected error for added key: %v", err)
+		}
+	})
+
+	t.Run("Invalid reload", func(t *testing.T) {
+		writeKeysFile(t, path, `{"keys": [{"id": "reader", "key": "qux", "scopes": ["write"]}]}`)
+
+		err := a.Reload()
+
+		if err == nil || !strings.Contains(err.Error(), `unknown scope "write"`) {
+			t.Errorf("error is %v, want unknown scope", err)
+		}
+
+		if _, err := a.Authenticate("baz"); err != nil {
+			t.Errorf("unexpected error for previous key: %v", err)
+		}
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		writeKeysFile(t, path, `{"keys": [{"id": "reader", "key": "quux", "scopes": ["read"]}]}`)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		go a.Watch(ctx, 10*time.Millisecond)
+
+		deadline := time.Now().Add(5 * time.Second)
+
+		for {
+			if _, err := a.Authenticate("quux"); err == nil {
+				break
+			}
+
+			if time.Now().After(deadline) {
+				t.Fatal("keys file not reloaded")
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	})
+}
+
+func TestVerifyToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Date(2023, 6, 13, 9, 0, 0, 0, time.UTC)
+
+	token, err := SignToken(secret, Principal{ID: "ci", Scopes: []string{"read", "admin"}}, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		p, err := VerifyToken(secret, token, now)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(p), "{ci [read admin]}"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	parts := strings.Split(token, ".")
+
+	for _, tc := range []struct {
+		desc   string
+		secret string
+		token  string
+		now    time.Time
+	}{
+		{"Expired", "secret", token, now.Add(time.Hour)},
+		{"Wrong secret", "terces", token, now},
+		{"Tampered claims", "secret", parts[0] + "." + b64.EncodeToString([]byte(`{"sub":"ci","scope":"admin","exp":9999999999}`)) + "." + parts[2], now},
+		{"Malformed", "secret", "foo.bar", now},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			if _, err := VerifyToken([]byte(tc.secret), tc.token, tc.now); !errors.Is(err, ErrUnauthenticated) {
+				t.Errorf("error is %v, want %v", err, ErrUnauthenticated)
+			}
+		})
+	}
+}
+
+func TestPrincipal_HasScope(t *testing.T) {
+	for _, tc := range []struct {
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{[]string{ScopeRead}, ScopeRead, true},
+		{[]string{ScopeRead}, ScopeAdmin, false},
+		{[]string{ScopeAdmin}, ScopeRead, true},
+		{nil, ScopeRead, false},
+	} {
+		p := Principal{ID: "foo", Scopes: tc.scopes}
+
+		if got := p.HasScope(tc.scope); got != tc.want {
+			t.Errorf("%v.HasScope(%q) = %t, want %t", tc.scopes, tc.scope, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Package chart renders simple charts as standalone SVG documents.
+package chart
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Colors used in charts. Presentation attributes are used rather than styles,
+// so that charts render under a strict Content-Security-Policy.
+const (
+	background = "#ffffff"
+	foreground = "#1d1d1f"
+	muted      = "#6e6e73"
+	grid       = "#e5e5ea"
+	accent     = "#0a66c2"
+)
+
+// Chart holds the title, axis labels and size of a chart.
+type Chart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	// BarLabel formats the labels of histogram bars, given the bounds of the
+	// bin and the number of values in it. Defaults to "%s to %s: %d".
+	BarLabel string
+	Width    int // defaults to 640
+	Height   int // defaults to 400
+}
+
+// Point is a labelled point, the label being shown when hovering it.
+type Point struct {
+	X, Y  float64
+	Label string
+}
+
+const (
+	marginTop    = 40
+	marginRight  = 20
+	marginBottom = 50
+	marginLeft   = 60
+)
+
+// Histogram writes a histogram of values, counted in bins of the given width
+// aligned to multiples of it.
+func (c Chart) Histogram(w io.Writer, values []float64, binWidth float64) error {
+	if binWidth <= 0 {
+		return fmt.Errorf("bin width must be positive, got %g", binWidth)
+	}
+
+	var (
+		lo, hi float64
+		counts []int
+	)
+
+	if len(values) > 0 {
+		lo = math.Floor(minOf(values)/binWidth) * binWidth
+		hi = math.Floor(maxOf(values)/binWidth)*binWidth + binWidth
+
+		counts = make([]int, int(math.Round((hi-lo)/binWidth)))
+
+		for _, v := range values {
+			counts[min(int((v-lo)/binWidth), len(counts)-1)]++
+		}
+	}
+
+	maxCount := 0
+	for _, n := range counts {
+		maxCount = max(maxCount, n)
+	}
+
+	barLabel := c.BarLabel
+	if barLabel == "" {
+		barLabel = "%s to %s: %d"
+	}
+
+	p := c.plot(w, lo, max(hi, lo+binWidth), 0, float64(max(maxCount, 1)))
+
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+
+		x0 := lo + float64(i)*binWidth
+		x1 := x0 + binWidth
+
+		p.printf(`<rect x="%s" y="%s" width="%s" height="%s" fill="%s"><title>%s</title></rect>`+"\n",
+			num(p.x(x0)+1), num(p.y(float64(n))), num(p.x(x1)-p.x(x0)-2), num(p.y(0)-p.y(float64(n))), accent,
+			escape(fmt.Sprintf(barLabel, num(x0), num(x1), n)))
+	}
+
+	return p.end()
+}
+
+// Scatter writes a scatter plot of points.
+func (c Chart) Scatter(w io.Writer, points []Point) error {
+	xs, ys := make([]float64, len(points)), make([]float64, len(points))
+
+	for i, pt := range points {
+		xs[i], ys[i] = pt.X, pt.Y
+	}
+
+	p := c.plot(w, min(0, minOf(xs)), maxOf(xs), min(0, minOf(ys)), maxOf(ys))
+
+	for _, pt := range points {
+		p.printf(`<circle cx="%s" cy="%s" r="4" fill="%s" fill-opacity="0.7"><title>%s</title></circle>`+"\n",
+			num(p.x(pt.X)), num(p.y(pt.Y)), accent, escape(pt.Label))
+	}
+
+	return p.end()
+}
+
+// Timeline writes points along the x axis, ignoring their y values. Points
+// too close to each other to be told apart are stacked in rows.
+func (c Chart) Timeline(w io.Writer, points []Point) error {
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].X < sorted[j].X
+	})
+
+	xs := make([]float64, len(sorted))
+	for i, pt := range sorted {
+		xs[i] = pt.X
+	}
+
+	c.YLabel = ""
+
+	p := c.plot(w, minOf(xs), maxOf(xs), 0, 1)
+
+	const (
+		radius = 4
+		gap    = 2 * radius
+	)
+
+	var rowEnds []float64 // x of the last point in each row
+
+	for _, pt := range sorted {
+		x := p.x(pt.X)
+
+		row := 0
+		for row < len(rowEnds) && x-rowEnds[row] < gap+1 {
+			row++
+		}
+		if row == len(rowEnds) {
+			rowEnds = append(rowEnds, 0)
+		}
+		rowEnds[row] = x
+
+		y := p.y(0) - gap - float64(row)*gap
+
+		p.printf(`<circle cx="%s" cy="%s" r="%d" fill="%s" fill-opacity="0.7"><title>%s</title></circle>`+"\n",
+			num(x), num(max(y, marginTop)), radius, accent, escape(pt.Label))
+	}
+
+	return p.end()
+}
+
+// plot writes the start of a chart with axes covering the given ranges, and
+// returns it for the caller to draw in.
+func (c Chart) plot(w io.Writer, xMin, xMax, yMin, yMax float64) *plot {
+	if c.Width == 0 {
+		c.Width = 640
+	}
+	if c.Height == 0 {
+		c.Height = 400
+	}
+
+	p := &plot{
+		w:     bufio.NewWriter(w),
+		chart: c,
+	}
+
+	p.xTicks, p.xMin, p.xMax = ticks(xMin, xMax)
+	p.yTicks, p.yMin, p.yMax = ticks(yMin, yMax)
+
+	p.printf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="system-ui, sans-serif" font-size="12" role="img">`+"\n",
+		c.Width, c.Height, c.Width, c.Height)
+	p.printf("<title>%s</title>\n", escape(c.Title))
+	p.printf(`<rect width="100%%" height="100%%" fill="%s"/>`+"\n", background)
+	p.printf(`<text x="%d" y="24" font-size="16" font-weight="600" fill="%s">%s</text>`+"\n", marginLeft, foreground, escape(c.Title))
+
+	// Charts without a y label, like timelines, have no y axis.
+	if c.YLabel != "" {
+		for _, t := range p.yTicks {
+			y := num(p.y(t))
+			p.printf(`<line x1="%d" y1="%s" x2="%d" y2="%s" stroke="%s"/>`+"\n", marginLeft, y, c.Width-marginRight, y, grid)
+			p.printf(`<text x="%d" y="%s" text-anchor="end" dominant-baseline="middle" fill="%s">%s</text>`+"\n", marginLeft-6, y, muted, num(t))
+		}
+	}
+
+	for _, t := range p.xTicks {
+		x := num(p.x(t))
+		p.printf(`<line x1="%s" y1="%d" x2="%s" y2="%d" stroke="%s"/>`+"\n", x, c.Height-marginBottom, x, c.Height-marginBottom+4, muted)
+		p.printf(`<text x="%s" y="%d" text-anchor="middle" fill="%s">%s</text>`+"\n", x, c.Height-marginBottom+18, muted, num(t))
+	}
+
+	p.printf(`<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="%s"/>`+"\n", marginLeft, c.Height-marginBottom, c.Width-marginRight, c.Height-marginBottom, foreground)
+	p.printf(`<text x="%d" y="%d" text-anchor="middle" fill="%s">%s</text>`+"\n", (marginLeft+c.Width-marginRight)/2, c.Height-12, foreground, escape(c.XLabel))
+
+	if c.YLabel != "" {
+		p.printf(`<text transform="translate(16 %d) rotate(-90)" text-anchor="middle" fill="%s">%s</text>`+"\n", (marginTop+c.Height-marginBottom)/2, foreground, escape(c.YLabel))
+	}
+
+	return p
+}
+
+type plot struct {
+	w      *bufio.Writer
+	chart  Chart
+	xMin   float64
+	xMax   float64
+	yMin   float64
+	yMax   float64
+	xTicks []float64
+	yTicks []float64
+}
+
+func (p *plot) printf(format string, args ...any) {
+	fmt.Fprintf(p.w, format, args...)
+}
+
+// x returns the horizontal position of v in the chart.
+func (p *plot) x(v float64) float64 {
+	return marginLeft + (v-p.xMin)/(p.xMax-p.xMin)*float64(p.chart.Width-marginLeft-marginRight)
+}
+
+// y returns the vertical position of v in the chart.
+func (p *plot) y(v float64) float64 {
+	return float64(p.chart.Height-marginBottom) - (v-p.yMin)/(p.yMax-p.yMin)*float64(p.chart.Height-marginTop-marginBottom)
+}
+
+func (p *plot) end() error {
+	p.printf("</svg>\n")
+	return p.w.Flush()
+}
+
+// ticks returns evenly spaced round values covering lo to hi, and the range
+// they span.
+func ticks(lo, hi float64) (ts []float64, tMin, tMax float64) {
+	if hi <= lo {
+		hi = lo + 1
+	}
+
+	step := niceStep((hi - lo) / 5)
+
+	// Fractional steps are applied by dividing, e.g. by 5 rather than
+	// multiplying by 0.2, which gives the nearest floats to round values.
+	value := func(k float64) float64 {
+		if step < 1 {
+			return k / math.Round(1/step)
+		}
+		return k * step
+	}
+
+	first, last := math.Floor(lo/step), math.Ceil(hi/step)
+
+	for k := first; k <= last; k++ {
+		ts = append(ts, value(k))
+	}
+
+	return ts, value(first), value(last)
+}
+
+// niceStep returns the smallest of 1, 2 or 5 times a power of 10 that is at
+// least step.
+func niceStep(step float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+
+	for _, f := range []float64{1, 2, 5, 10} {
+		if f*magnitude >= step {
+			return f * magnitude
+		}
+	}
+
+	return 10 * magnitude
+}
+
+func minOf(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	m := vs[0]
+	for _, v := range vs[1:] {
+		m = min(m, v)
+	}
+	return m
+}
+
+func maxOf(vs []float64) float64 {
+	if len(vs) == 0 {
+		return 0
+	}
+	m := vs[0]
+	for _, v := range vs[1:] {
+		m = max(m, v)
+	}
+	return m
+}
+
+// num formats v with at most two decimals.
+func num(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,166 @@
+package chart
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// elements returns the number of elements of each name in the SVG document s,
+// failing if it is not well-formed XML.
+func elements(t *testing.T, s string) map[string]int {
+	t.Helper()
+
+	counts := make(map[string]int)
+
+	d := xml.NewDecoder(strings.NewReader(s))
+
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("invalid XML: %v\n%s", err, s)
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			counts[el.Name.Local]++
+		}
+	}
+
+	return counts
+}
+
+func TestChart_Histogram(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var buf strings.Builder
+
+		err := Chart{Title: "foo", XLabel: "x", YLabel: "y"}.Histogram(&buf, []float64{12, 13, 21, 24, 24.5, 48}, 5)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// One background rect, plus a bar for each of the bins 10-15, 20-25 and
+		// 45-50, which are not empty
+		if got, want := elements(t, buf.String())["rect"], 4; got != want {
+			t.Errorf("got %d rects, want %d", got, want)
+		}
+
+		for _, want := range []string{
+			"<title>10 to 15: 2</title>",
+			"<title>20 to 25: 3</title>",
+			"<title>45 to 50: 1</title>",
+		} {
+			if !strings.Contains(buf.String(), want) {
+				t.Errorf("chart does not contain %q:\n%s", want, buf.String())
+			}
+		}
+	})
+
+	t.Run("Bar label", func(t *testing.T) {
+		var buf strings.Builder
+
+		if err := (Chart{BarLabel: "%s till %s: %d"}).Histogram(&buf, []float64{12, 13}, 5); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if want := "<title>10 till 15: 2</title>"; !strings.Contains(buf.String(), want) {
+			t.Errorf("chart does not contain %q:\n%s", want, buf.String())
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var buf strings.Builder
+
+		if err := (Chart{}).Histogram(&buf, nil, 5); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		elements(t, buf.String())
+	})
+
+	t.Run("Invalid bin width", func(t *testing.T) {
+		if err := (Chart{}).Histogram(io.Discard, nil, 0); err == nil {
+			t.Error("error is nil")
+		}
+	})
+}
+
+func TestChart_Scatter(t *testing.T) {
+	var buf strings.Builder
+
+	err := Chart{Title: "foo", XLabel: "x", YLabel: "y"}.Scatter(&buf, []Point{
+		{X: 172, Y: 77, Label: "Luke Skywalker"},
+		{X: 96, Y: 32, Label: "<R2-D2>"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := elements(t, buf.String())["circle"], 2; got != want {
+		t.Errorf("got %d circles, want %d", got, want)
+	}
+
+	if want := "<title>&lt;R2-D2&gt;</title>"; !strings.Contains(buf.String(), want) {
+		t.Errorf("chart does not contain %q:\n%s", want, buf.String())
+	}
+}
+
+func TestChart_Timeline(t *testing.T) {
+	var buf strings.Builder
+
+	err := Chart{Title: "foo", XLabel: "x"}.Timeline(&buf, []Point{
+		{X: -19, Label: "Luke Skywalker"},
+		{X: -19, Label: "Leia Organa"},
+		{X: -896, Label: "Yoda"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	counts := elements(t, buf.String())
+
+	if got, want := counts["circle"], 3; got != want {
+		t.Errorf("got %d circles, want %d", got, want)
+	}
+
+	// Luke and Leia share a birth year, so they are stacked
+	var cys []string
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.HasPrefix(line, "<circle") {
+			var cx, cy string
+			fmt.Sscanf(line, `<circle cx=%q cy=%q`, &cx, &cy)
+			cys = append(cys, cy)
+		}
+	}
+
+	if len(cys) == 3 && cys[1] == cys[2] {
+		t.Errorf("points with the same x are not stacked: %v", cys)
+	}
+}
+
+func TestTicks(t *testing.T) {
+	for n, tc := range []struct {
+		lo, hi  float64
+		want    string
+		wantMin float64
+		wantMax float64
+	}{
+		{0, 10, "[0 2 4 6 8 10]", 0, 10},
+		{0, 1358, "[0 500 1000 1500]", 0, 1500},
+		{-896, 22, "[-1000 -800 -600 -400 -200 0 200]", -1000, 200},
+		{5, 5, "[5 5.2 5.4 5.6 5.8 6]", 5, 6},
+	} {
+		ts, tMin, tMax := ticks(tc.lo, tc.hi)
+
+		if got := fmt.Sprint(ts); got != tc.want {
+			t.Errorf("[%d] got ticks %s, want %s", n, got, tc.want)
+		}
+
+		if tMin != tc.wantMin || tMax != tc.wantMax {
+			t.Errorf("[%d] got range %g to %g, want %g to %g", n, tMin, tMax, tc.wantMin, tc.wantMax)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package cli implements the command-line interface, which runs the server or
+// queries the rankings directly without it.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/config"
+	"github.com/jsageryd/starwars-coding-test/core"
+	"github.com/jsageryd/starwars-coding-test/dataset"
+	"github.com/jsageryd/starwars-coding-test/logging"
+	"github.com/jsageryd/starwars-coding-test/swapi"
+	"github.com/jsageryd/starwars-coding-test/tracing"
+)
+
+type command struct {
+	name    string // words of the command, e.g. "top fat"
+	args    string // positional arguments, for usage
+	summary string
+	formats []format // output formats, the first being the default
+	run     func(ctx context.Context, env *env) error
+}
+
+// env is the environment a command runs in.
+type env struct {
+	cfg    config.Config
+	format format
+	args   []string
+	stdout io.Writer
+}
+
+var commands = []command{
+	{
+		name:    "serve",
+		summary: "run the HTTP server (default)",
+		run: func(ctx context.Context, env *env) error {
+			return serve(ctx, env.cfg)
+		},
+	},
+	{
+		name:    "top fat",
+		summary: "list the fattest characters by BMI",
+		formats: outputFormats,
+		run: func(ctx context.Context, env *env) error {
+			c, err := newCore(env.cfg)
+			if err != nil {
+				return err
+			}
+
+			characters, err := c.TopFatCharacters(ctx)
+			if err != nil {
+				return err
+			}
+			return writeCharacters(env.stdout, env.format, characters)
+		},
+	},
+	{
+		name:    "top old",
+		summary: "list the oldest characters by birth year",
+		formats: outputFormats,
+		run: func(ctx context.Context, env *env) error {
+			c, err := newCore(env.cfg)
+			if err != nil {
+				return err
+			}
+
+			characters, err := c.TopOldCharacters(ctx)
+			if err != nil {
+				return err
+			}
+			return writeCharacters(env.stdout, env.format, characters)
+		},
+	},
+	{
+		name:    "character get",
+		args:    "<name>",
+		summary: "show the character with the given name",
+		formats: outputFormats,
+		run: func(ctx context.Context, env *env) error {
+			if len(env.args) == 0 {
+				return errors.New("missing character name")
+			}
+
+			c, err := newCore(env.cfg)
+			if err != nil {
+				return err
+			}
+
+			character, err := c.Character(ctx, strings.Join(env.args, " "))
+			if err != nil {
+				return err
+			}
+
+			return writeCharacter(env.stdout, env.format, character)
+		},
+	},
+	{
+		name:    "export",
+		summary: "list all characters, or write them to a snapshot for use with -dataset",
+		formats: append(outputFormats, formatSnapshot),
+		run: func(ctx context.Context, env *env) error {
+			c, err := newCore(env.cfg)
+			if err != nil {
+				return err
+			}
+
+			characters, err := c.Characters(ctx)
+			if err != nil {
+				return err
+			}
+
+			if env.format == formatSnapshot {
+				s, err := dataset.New(characters, time.Now())
+				if err != nil {
+					return err
+				}
+				return s.Write(env.stdout)
+			}
+
+			return writeCharacters(env.stdout, env.format, characters)
+		},
+	},
+	{
+		name:    "import",
+		args:    "<file>",
+		summary: "verify a snapshot written by export and show what it contains",
+		run: func(ctx context.Context, env *env) error {
+			if len(env.args) != 1 {
+				return errors.New("expected a single snapshot file")
+			}
+
+			s, err := dataset.ReadFile(env.args[0])
+			if err != nil {
+				return err
+			}
+
+			return writeSnapshotSummary(env.stdout, s)
+		},
+	},
+	{
+		name:    "token",
+		args:    "<subject> <scope>...",
+		summary: "issue a bearer token signed with the secret of the -auth-keys file",
+		run: func(ctx context.Context, env *env) error {
+			if len(env.args) < 2 {
+				return errors.New("expected a subject and at least one scope")
+			}
+
+			if env.cfg.AuthKeys == "" {
+				return errors.New("no keys file given, use -auth-keys")
+			}
+
+			token, err := issueToken(env.cfg, env.args[0], env.args[1:])
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintln(env.stdout, token)
+			return err
+		},
+	},
+}
+
+// Run runs the command given by args and returns the exit status. Without a
+// command, the server is run.
+func Run(ctx context.Context, args []string, stdout, stderr io.Writer, getenv func(string) string) int {
+	cmd, rest, ok := findCommand(args)
+	if !ok {
+		printUsage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s\n\n%s.\n\nFlags:\n", strings.TrimSpace(cmd.name+" [flags] "+cmd.args), capitalize(cmd.summary))
+		fs.PrintDefaults()
+	}
+
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+
+	var formatName *string
+	if len(cmd.formats) > 0 {
+		formatName = fs.String("format", string(cmd.formats[0]), "output format: "+formatList(cmd.formats))
+	}
+
+	cfg, err := config.Load(fs, rest, getenv)
+	if err != nil {
+		switch {
+		case errors.Is(err, flag.ErrHelp):
+			return 0
+		case errors.Is(err, config.ErrParse):
+			return 2
+		default:
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	if *printConfig {
+		cfg.Print(stdout)
+		return 0
+	}
+
+	logger, err := logging.New(stderr, cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	slog.SetDefault(logger)
+
+	tracer := newTracer(cfg, stderr)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+		defer cancel()
+
+		if err := tracer.Shutdown(ctx); err != nil {
+			slog.Error("Error shutting down tracer", "error", err)
+		}
+	}()
+
+	tracing.SetDefault(tracer)
+
+	e := &env{
+		cfg:    cfg,
+		args:   fs.Args(),
+		stdout: stdout,
+	}
+
+	if len(cmd.formats) > 0 {
+		if e.format, err = parseFormat(*formatName, cmd.formats); err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	if err := cmd.run(ctx, e); err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// findCommand returns the command named by the leading words of args and the
+// remaining arguments.
+func findCommand(args []string) (cmd command, rest []string, ok bool) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return commands[0], args, true
+	}
+
+	for _, cmd := range commands {
+		words := strings.Fields(cmd.name)
+
+		if len(args) < len(words) {
+			continue
+		}
+
+		if strings.Join(args[:len(words)], " ") == cmd.name {
+			return cmd, args[len(words):], true
+		}
+	}
+
+	return command{}, nil, false
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: <command> [flags]\n\nCommands:\n")
+
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-28s %s\n", strings.TrimSpace(cmd.name+" "+cmd.args), cmd.summary)
+	}
+
+	fmt.Fprintf(w, "\nRun '<command> -h' for the flags of a command.\n")
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// issueToken returns a bearer token for subject with the given scopes, signed
+// with the secret in the configured keys file.
+func issueToken(cfg config.Config, subject string, scopes []string) (string, error) {
+	b, err := os.ReadFile(cfg.AuthKeys)
+	if err != nil {
+		return "", fmt.Errorf("error reading keys file: %v", err)
+	}
+
+	var f auth.KeysFile
+
+	if err := json.Unmarshal(b, &f); err != nil {
+		return "", fmt.Errorf("error parsing keys file %s: %v", cfg.AuthKeys, err)
+	}
+
+	if f.TokenSecret == "" {
+		return "", fmt.Errorf("keys file %s has no token secret", cfg.AuthKeys)
+	}
+
+	for _, scope := range scopes {
+		if scope != auth.ScopeRead && scope != auth.ScopeAdmin {
+			return "", fmt.Errorf("unknown scope %q, want %s or %s", scope, auth.ScopeRead, auth.ScopeAdmin)
+		}
+	}
+
+	return auth.SignToken(
+		[]byte(f.TokenSecret),
+		auth.Principal{ID: subject, Scopes: scopes},
+		time.Now().Add(time.Duration(cfg.TokenTTL)),
+	)
+}
+
+// newTracer returns a tracer exporting spans as configured.
+func newTracer(cfg config.Config, stderr io.Writer) *tracing.Tracer {
+	switch cfg.TraceExporter {
+	case "stderr":
+		return tracing.NewTracer(tracing.NewJSONExporter(stderr))
+	case "otlp":
+		return tracing.NewTracer(tracing.NewOTLPExporter(cfg.OTLPEndpoint, "starwars"))
+	default:
+		return tracing.NewTracer(nil)
+	}
+}
+
+// newSWAPIClient returns a SWAPI client as configured, serving the characters
+// in the configured dataset instead of querying SWAPI if there is one.
+func newSWAPIClient(cfg config.Config, opts ...swapi.Option) (*swapi.Client, error) {
+	opts = append([]swapi.Option{
+		swapi.WithCacheTTL(time.Duration(cfg.CacheTTL)),
+		swapi.WithRetries(cfg.Retries),
+		swapi.WithTimeout(time.Duration(cfg.UpstreamTimeout)),
+	}, opts...)
+
+	if cfg.Dataset != "" {
+		s, err := dataset.ReadFile(cfg.Dataset)
+		if err != nil {
+			return nil, fmt.Errorf("error loading dataset: %v", err)
+		}
+
+		opts = append(opts, swapi.WithOfflineData(s.Characters))
+	}
+
+	return swapi.NewClient(cfg.SWAPIBaseURL, opts...), nil
+}
+
+func newCore(cfg config.Config) (*core.Core, error) {
+	swapiClient, err := newSWAPIClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return core.New(
+		swapiClient,
+		core.WithLimit(cfg.TopN),
+	), nil
+}
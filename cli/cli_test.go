@@ -0,0 +1,417 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/api"
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/core"
+	"github.com/jsageryd/starwars-coding-test/swapi"
+)
+
+func newSWAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`
+{
+  "results": [
+    {"name":"Luke Skywalker","height":"172","mass":"77","birth_year":"19BBY"},
+    {"name":"R2-D2","height":"96","mass":"32","birth_year":"33BBY"},
+    {"name":"C-3PO","height":"167","mass":"75","birth_year":"112BBY"}
+  ]
+}
+`))
+		},
+	))
+
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func run(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+
+	var outBuf, errBuf strings.Builder
+
+	code = Run(context.Background(), args, &outBuf, &errBuf, func(string) string { return "" })
+
+	return code, outBuf.String(), errBuf.String()
+}
+
+func TestRun(t *testing.T) {
+	ts := newSWAPIServer(t)
+
+	for _, tc := range []struct {
+		desc       string
+		args       []string
+		wantCode   int
+		wantStdout string
+	}{
+		{
+			desc:     "Top fat",
+			args:     []string{"top", "fat", "-swapi-base-url", ts.URL, "-top-n", "2"},
+			wantCode: 0,
+			wantStdout: "" +
+				"NAME   HEIGHT  MASS  BIRTH YEAR\n" +
+				"R2-D2  96      32    33BBY\n" +
+				"C-3PO  167     75    112BBY\n",
+		},
+		{
+			desc:     "Top old",
+			args:     []string{"top", "old", "-swapi-base-url", ts.URL, "-format", "csv"},
+			wantCode: 0,
+			wantStdout: "" +
+				"name,height,mass,birth_year\n" +
+				"C-3PO,167,75,112BBY\n" +
+				"R2-D2,96,32,33BBY\n" +
+				"Luke Skywalker,172,77,19BBY\n",
+		},
+		{
+			desc:     "Character get",
+			args:     []string{"character", "get", "-swapi-base-url", ts.URL, "-format", "json", "luke", "skywalker"},
+			wantCode: 0,
+			wantStdout: `{
+  "name": "Luke Skywalker",
+  "height": "172",
+  "mass": "77",
+  "birth_year": "19BBY"
+}
+`,
+		},
+		{
+			desc:     "Character not found",
+			args:     []string{"character", "get", "-swapi-base-url", ts.URL, "Jar Jar Binks"},
+			wantCode: 1,
+		},
+		{
+			desc:     "Character name missing",
+			args:     []string{"character", "get", "-swapi-base-url", ts.URL},
+			wantCode: 1,
+		},
+		{
+			desc:     "Export",
+			args:     []string{"export", "-swapi-base-url", ts.URL, "-format", "csv"},
+			wantCode: 0,
+			wantStdout: "" +
+				"name,height,mass,birth_year\n" +
+				"Luke Skywalker,172,77,19BBY\n" +
+				"R2-D2,96,32,33BBY\n" +
+				"C-3PO,167,75,112BBY\n",
+		},
+		{
+			desc:     "Export traced",
+			args:     []string{"export", "-swapi-base-url", ts.URL, "-format", "csv", "-trace-exporter", "stderr"},
+			wantCode: 0,
+			wantStdout: "" +
+				"name,height,mass,birth_year\n" +
+				"Luke Skywalker,172,77,19BBY\n" +
+				"R2-D2,96,32,33BBY\n" +
+				"C-3PO,167,75,112BBY\n",
+		},
+		{
+			desc:     "Unknown command",
+			args:     []string{"foo"},
+			wantCode: 2,
+		},
+		{
+			desc:     "Unknown flag",
+			args:     []string{"top", "fat", "-foo"},
+			wantCode: 2,
+		},
+		{
+			desc:     "Unknown format",
+			args:     []string{"top", "fat", "-format", "xml"},
+			wantCode: 2,
+		},
+		{
+			desc:     "Invalid configuration",
+			args:     []string{"top", "fat", "-top-n", "0"},
+			wantCode: 1,
+		},
+		{
+			desc:     "Help",
+			args:     []string{"top", "fat", "-h"},
+			wantCode: 0,
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			code, stdout, stderr := run(t, tc.args...)
+
+			if got, want := code, tc.wantCode; got != want {
+				t.Errorf("got exit status %d, want %d; stderr:\n%s", got, want, stderr)
+			}
+
+			if got, want := stdout, tc.wantStdout; got != want {
+				t.Errorf("got stdout:\n%s\nwant:\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestRun_PrintConfig(t *testing.T) {
+	code, stdout, _ := run(t, "-print-config", "-top-n", "5")
+
+	if got, want := code, 0; got != want {
+		t.Errorf("got exit status %d, want %d", got, want)
+	}
+
+	if want := `"top_n": 5`; !strings.Contains(stdout, want) {
+		t.Errorf("stdout does not contain %q:\n%s", want, stdout)
+	}
+}
+
+func TestRun_ServeListenError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	ts := newSWAPIServer(t)
+
+	code, _, stderr := run(t, "serve", "-addr", l.Addr().String(), "-swapi-base-url", ts.URL)
+
+	if got, want := code, 1; got != want {
+		t.Errorf("got exit status %d, want %d", got, want)
+	}
+
+	if want := "address already in use"; !strings.Contains(stderr, want) {
+		t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+	}
+}
+
+func TestRun_ServeUnknownRateLimitRoute(t *testing.T) {
+	ts := newSWAPIServer(t)
+
+	code, _, stderr := run(t, "serve", "-addr", "127.0.0.1:0", "-swapi-base-url", ts.URL, "-rate-limits", "*=10/s,/top-thin-characters=1/s")
+
+	if got, want := code, 1; got != want {
+		t.Errorf("got exit status %d, want %d", got, want)
+	}
+
+	if want := `rate_limits: unknown route "/top-thin-characters"`; !strings.Contains(stderr, want) {
+		t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+	}
+}
+
+func TestRefreshCache(t *testing.T) {
+	t.Run("Warm-up recovers", func(t *testing.T) {
+		var failing atomic.Bool
+		var gotReqCount atomic.Int32
+
+		failing.Store(true)
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotReqCount.Add(1)
+
+				if failing.Load() {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				w.Write([]byte(`{"results": [{"name":"C-3PO","height":"167","mass":"75","birth_year":"112BBY"}]}`))
+			},
+		))
+		defer ts.Close()
+
+		swapiClient := swapi.NewClient(ts.URL)
+
+		mux := http.NewServeMux()
+		api.New(core.New(swapiClient), api.WithReadinessGate()).Register(mux)
+
+		get := func() int {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/top-fat-characters", nil))
+			return w.Code
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			refreshCache(ctx, swapiClient, time.Hour, time.Millisecond)
+		}()
+
+		waitFor := func(what string, cond func() bool) {
+			t.Helper()
+
+			for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatalf("timed out waiting for %s", what)
+				}
+			}
+		}
+
+		waitFor("retries", func() bool { return gotReqCount.Load() >= 3 })
+
+		if got, want := get(), http.StatusServiceUnavailable; got != want {
+			t.Errorf("got HTTP %d while SWAPI is down, want %d", got, want)
+		}
+
+		failing.Store(false)
+
+		waitFor("recovery", func() bool { return get() == http.StatusOK })
+
+		cancel()
+		<-done
+	})
+
+	t.Run("Cancelled during warm-up", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+		))
+		defer ts.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		done := make(chan struct{})
+
+		go func() {
+			defer close(done)
+			refreshCache(ctx, swapi.NewClient(ts.URL), time.Hour, time.Hour)
+		}()
+
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("refreshCache did not return")
+		}
+	})
+}
+
+func TestRun_ExportImport(t *testing.T) {
+	ts := newSWAPIServer(t)
+
+	code, snapshot, stderr := run(t, "export", "-swapi-base-url", ts.URL, "-format", "snapshot")
+	if code != 0 {
+		t.Fatalf("export: got exit status %d; stderr:\n%s", code, stderr)
+	}
+
+	snapshotFile := filepath.Join(t.TempDir(), "snapshot.json")
+
+	if err := os.WriteFile(snapshotFile, []byte(snapshot), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ts.Close()
+
+	t.Run("Import", func(t *testing.T) {
+		code, stdout, stderr := run(t, "import", snapshotFile)
+
+		if got, want := code, 0; got != want {
+			t.Errorf("got exit status %d, want %d; stderr:\n%s", got, want, stderr)
+		}
+
+		for _, want := range []string{
+			"Schema version:  2\n",
+			"(verified)\n",
+			"Characters:      3\n",
+		} {
+			if !strings.Contains(stdout, want) {
+				t.Errorf("stdout does not contain %q:\n%s", want, stdout)
+			}
+		}
+	})
+
+	t.Run("Dataset as data source", func(t *testing.T) {
+		code, stdout, stderr := run(t, "top", "old", "-swapi-base-url", ts.URL, "-dataset", snapshotFile, "-top-n", "1", "-format", "csv")
+
+		if got, want := code, 0; got != want {
+			t.Errorf("got exit status %d, want %d; stderr:\n%s", got, want, stderr)
+		}
+
+		if got, want := stdout, "name,height,mass,birth_year\nC-3PO,167,75,112BBY\n"; got != want {
+			t.Errorf("got stdout:\n%s\nwant:\n%s", got, want)
+		}
+	})
+
+	t.Run("Corrupt snapshot", func(t *testing.T) {
+		corruptFile := filepath.Join(t.TempDir(), "corrupt.json")
+
+		if err := os.WriteFile(corruptFile, []byte(strings.Replace(snapshot, "C-3PO", "C-4PO", 1)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		code, _, stderr := run(t, "import", corruptFile)
+
+		if got, want := code, 1; got != want {
+			t.Errorf("got exit status %d, want %d", got, want)
+		}
+
+		if want := "checksum mismatch"; !strings.Contains(stderr, want) {
+			t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+		}
+	})
+
+	t.Run("Snapshot format only for export", func(t *testing.T) {
+		code, _, _ := run(t, "top", "fat", "-format", "snapshot")
+
+		if got, want := code, 2; got != want {
+			t.Errorf("got exit status %d, want %d", got, want)
+		}
+	})
+}
+
+func TestRun_Token(t *testing.T) {
+	keysFile := filepath.Join(t.TempDir(), "keys.json")
+
+	if err := os.WriteFile(keysFile, []byte(`{"token_secret": "secret"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		code, stdout, stderr := run(t, "token", "-auth-keys", keysFile, "ci", "read")
+
+		if got, want := code, 0; got != want {
+			t.Fatalf("got exit status %d, want %d; stderr:\n%s", got, want, stderr)
+		}
+
+		p, err := auth.VerifyToken([]byte("secret"), strings.TrimSpace(stdout), time.Now())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(p), "{ci [read]}"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Unknown scope", func(t *testing.T) {
+		code, _, stderr := run(t, "token", "-auth-keys", keysFile, "ci", "write")
+
+		if got, want := code, 1; got != want {
+			t.Errorf("got exit status %d, want %d", got, want)
+		}
+
+		if !strings.Contains(stderr, `unknown scope "write"`) {
+			t.Errorf("stderr does not mention the unknown scope:\n%s", stderr)
+		}
+	})
+
+	t.Run("No keys file", func(t *testing.T) {
+		if code, _, _ := run(t, "token", "ci", "read"); code != 1 {
+			t.Errorf("got exit status %d, want 1", code)
+		}
+	})
+}
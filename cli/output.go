@@ -0,0 +1,119 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/dataset"
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+type format string
+
+const (
+	formatTable    format = "table"
+	formatJSON     format = "json"
+	formatCSV      format = "csv"
+	formatSnapshot format = "snapshot"
+)
+
+// outputFormats are the formats of commands that output characters.
+var outputFormats = []format{formatTable, formatJSON, formatCSV}
+
+// parseFormat returns the format named s if it is one of the allowed formats.
+func parseFormat(s string, allowed []format) (format, error) {
+	for _, f := range allowed {
+		if format(s) == f {
+			return f, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown output format %q, want %s", s, formatList(allowed))
+}
+
+// formatList returns the formats as a list for humans, e.g. "a, b or c".
+func formatList(formats []format) string {
+	names := make([]string, len(formats))
+	for i, f := range formats {
+		names[i] = string(f)
+	}
+
+	if len(names) == 1 {
+		return names[0]
+	}
+
+	return strings.Join(names[:len(names)-1], ", ") + " or " + names[len(names)-1]
+}
+
+var columns = []string{"name", "height", "mass", "birth_year"}
+
+// row returns the columns of c, leaving unknown values empty.
+func row(c starwars.Character) []string {
+	return []string{
+		c.Name,
+		formatNumber(c.Height),
+		formatNumber(c.Mass),
+		c.BirthYear,
+	}
+}
+
+func formatNumber(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func writeCharacters(w io.Writer, f format, cs []starwars.Character) error {
+	switch f {
+	case formatJSON:
+		if cs == nil {
+			cs = []starwars.Character{}
+		}
+		return writeJSON(w, cs)
+	case formatCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(columns)
+		for _, c := range cs {
+			cw.Write(row(c))
+		}
+		cw.Flush()
+		return cw.Error()
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAME\tHEIGHT\tMASS\tBIRTH YEAR")
+		for _, c := range cs {
+			r := row(c)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r[0], r[1], r[2], r[3])
+		}
+		return tw.Flush()
+	}
+}
+
+func writeCharacter(w io.Writer, f format, c starwars.Character) error {
+	if f == formatJSON {
+		return writeJSON(w, c)
+	}
+	return writeCharacters(w, f, []starwars.Character{c})
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeSnapshotSummary(w io.Writer, s *dataset.Snapshot) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Schema version:\t%d\n", s.SchemaVersion)
+	fmt.Fprintf(tw, "Created at:\t%s\n", s.CreatedAt.Format(time.RFC3339))
+	fmt.Fprintf(tw, "Checksum:\t%s (verified)\n", s.Checksum)
+	fmt.Fprintf(tw, "Characters:\t%d\n", len(s.Characters))
+	return tw.Flush()
+}
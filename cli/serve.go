@@ -0,0 +1,235 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/api"
+	"github.com/jsageryd/starwars-coding-test/auth"
+	"github.com/jsageryd/starwars-coding-test/config"
+	"github.com/jsageryd/starwars-coding-test/core"
+	"github.com/jsageryd/starwars-coding-test/metrics"
+	"github.com/jsageryd/starwars-coding-test/swapi"
+)
+
+// serve runs the HTTP server and a background cache refresher until ctx is
+// cancelled, then drains in-flight requests for at most the configured
+// shutdown timeout.
+func serve(ctx context.Context, cfg config.Config) error {
+	mux := http.NewServeMux()
+
+	reg := metrics.NewRegistry()
+
+	swapiClient, err := newSWAPIClient(cfg, swapi.WithMetrics(reg))
+	if err != nil {
+		return err
+	}
+
+	apiOpts := []api.Option{
+		api.WithUpstreamBudget(time.Duration(cfg.UpstreamBudget)),
+	}
+
+	if cfg.ReadinessGate {
+		apiOpts = append(apiOpts, api.WithReadinessGate())
+	}
+
+	if cfg.Metrics {
+		apiOpts = append(apiOpts, api.WithMetrics(reg))
+	}
+
+	if cfg.LegacySunset != "" {
+		// Validated by config.Load.
+		sunset, _ := time.Parse(time.DateOnly, cfg.LegacySunset)
+		apiOpts = append(apiOpts, api.WithLegacySunset(sunset))
+	}
+
+	if cfg.Compression {
+		apiOpts = append(apiOpts, api.WithCompression(cfg.CompressMinSize))
+	}
+
+	if origins := config.SplitList(cfg.CORSOrigins); len(origins) > 0 {
+		apiOpts = append(apiOpts, api.WithCORS(api.CORS{
+			AllowedOrigins:   origins,
+			AllowedMethods:   config.SplitList(cfg.CORSMethods),
+			AllowedHeaders:   config.SplitList(cfg.CORSHeaders),
+			ExposedHeaders:   []string{"ETag", "X-Request-ID", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
+			AllowCredentials: cfg.CORSCredentials,
+			MaxAge:           time.Duration(cfg.CORSMaxAge),
+		}))
+	}
+
+	var authn *auth.Authenticator
+
+	if cfg.AuthKeys != "" {
+		if authn, err = auth.NewFromFile(cfg.AuthKeys); err != nil {
+			return err
+		}
+		apiOpts = append(apiOpts, api.WithAuth(authn))
+
+		if cfg.AuthFailures != "" {
+			// Validated by config.Load.
+			limit, _ := config.ParseRateLimit(cfg.AuthFailures)
+			apiOpts = append(apiOpts, api.WithAuthFailureLimit(api.RateLimit{Rate: limit.Rate, Burst: limit.Burst}))
+		}
+	}
+
+	// Validated by config.Load, except for the routes.
+	limits, _ := config.ParseRateLimits(cfg.RateLimits)
+
+	if err := checkRateLimitRoutes(limits); err != nil {
+		return err
+	}
+
+	if len(limits) > 0 {
+		rateLimits := make(map[string]api.RateLimit, len(limits))
+		for route, limit := range limits {
+			rateLimits[route] = api.RateLimit{Rate: limit.Rate, Burst: limit.Burst}
+		}
+		apiOpts = append(apiOpts, api.WithRateLimits(rateLimits))
+	}
+
+	// Background work, such as refreshing the cache, is stopped on shutdown.
+	refreshCtx, cancelRefresh := context.WithCancel(ctx)
+
+	apiOpts = append(apiOpts, api.WithContext(refreshCtx))
+
+	a := api.New(
+		core.New(
+			swapiClient,
+			core.WithLimit(cfg.TopN),
+		),
+		apiOpts...,
+	)
+
+	a.Register(mux)
+
+	srv := &http.Server{
+		Addr:         cfg.Addr,
+		Handler:      mux,
+		ReadTimeout:  time.Duration(cfg.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.IdleTimeout),
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		refreshCache(refreshCtx, swapiClient, time.Duration(cfg.RefreshInterval), warmUpBackoff)
+	}()
+
+	if authn != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			authn.Watch(refreshCtx, time.Duration(cfg.AuthReload))
+		}()
+	}
+
+	defer func() {
+		cancelRefresh()
+		wg.Wait()
+		a.Wait()
+	}()
+
+	errc := make(chan error, 1)
+
+	go func() {
+		slog.Info("Listening", "addr", srv.Addr)
+		errc <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errc:
+		return fmt.Errorf("error listening at %s: %v", srv.Addr, err)
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout))
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("error shutting down: %v", err)
+	}
+
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("error listening at %s: %v", srv.Addr, err)
+	}
+
+	slog.Info("Shut down")
+
+	return nil
+}
+
+// warmUpBackoff is how long to wait before retrying a failed warm-up of the
+// cache. It doubles on every failure, up to the refresh interval.
+const warmUpBackoff = time.Second
+
+// checkRateLimitRoutes returns an error if there are rate limits for routes
+// the API does not serve, which would otherwise be ignored.
+func checkRateLimitRoutes(limits map[string]config.RateLimit) error {
+	routes := api.Routes()
+
+	var unknown []string
+	for route := range limits {
+		if route != "*" && !slices.Contains(routes, route) {
+			unknown = append(unknown, route)
+		}
+	}
+
+	slices.Sort(unknown)
+
+	var errs []error
+	for _, route := range unknown {
+		errs = append(errs, fmt.Errorf("rate_limits: unknown route %q", route))
+	}
+
+	return errors.Join(errs...)
+}
+
+// refreshCache warms up the cache, retrying with backoff until it succeeds, and
+// then keeps refreshing it at the given interval until ctx is cancelled.
+func refreshCache(ctx context.Context, swapiClient *swapi.Client, interval, backoff time.Duration) {
+	slog.InfoContext(ctx, "Warming up cache")
+
+	for {
+		err := swapiClient.Refresh(ctx)
+		if err == nil {
+			slog.InfoContext(ctx, "Cache warm")
+			break
+		}
+
+		slog.ErrorContext(ctx, "Error warming up cache", "error", err, "retry_in", backoff)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(2*backoff, interval)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := swapiClient.Refresh(ctx); err != nil {
+				slog.ErrorContext(ctx, "Error refreshing cache", "error", err)
+			}
+		}
+	}
+}
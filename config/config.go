@@ -0,0 +1,620 @@
+// Package config loads the service configuration by layering defaults, a
+// config file, environment variables and command-line flags, in that order.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of environment variables holding configuration,
+// e.g. STARWARS_CACHE_TTL for the cache-ttl setting.
+const EnvPrefix = "STARWARS_"
+
+// ErrParse is wrapped by errors from Load caused by command-line arguments
+// that could not be parsed. Such errors have already been reported by the
+// flag set.
+var ErrParse = errors.New("error parsing arguments")
+
+type Config struct {
+	Addr            string   `json:"addr"`              // address to listen at
+	SWAPIBaseURL    string   `json:"swapi_base_url"`    // base URL of SWAPI
+	Dataset         string   `json:"dataset"`           // snapshot to use instead of SWAPI, if any
+	TopN            int      `json:"top_n"`             // number of characters in rankings and search results
+	CacheTTL        Duration `json:"cache_ttl"`         // how long SWAPI data is cached, 0 for indefinitely
+	RefreshInterval Duration `json:"refresh_interval"`  // how often the cache is refreshed in the background
+	Retries         int      `json:"retries"`           // retries of failed SWAPI requests
+	UpstreamTimeout Duration `json:"upstream_timeout"`  // timeout of a single SWAPI request
+	UpstreamBudget  Duration `json:"upstream_budget"`   // time the readiness check waits for SWAPI
+	ReadTimeout     Duration `json:"read_timeout"`      // maximum duration for reading a request
+	WriteTimeout    Duration `json:"write_timeout"`     // maximum duration for writing a response
+	IdleTimeout     Duration `json:"idle_timeout"`      // maximum time to keep idle connections open
+	ShutdownTimeout Duration `json:"shutdown_timeout"`  // maximum time to wait for in-flight requests on shutdown
+	Metrics         bool     `json:"metrics"`           // serve metrics at /metrics
+	ReadinessGate   bool     `json:"readiness_gate"`    // reject traffic until the cache is warm
+	LogLevel        string   `json:"log_level"`         // debug, info, warn or error
+	LogFormat       string   `json:"log_format"`        // text or json
+	RateLimits      string   `json:"rate_limits"`       // per-route rate limits, see ParseRateLimits
+	Compression     bool     `json:"compression"`       // compress responses if the client accepts it
+	CompressMinSize int      `json:"compress_min_size"` // minimum size in bytes of responses to compress
+	CORSOrigins     string   `json:"cors_origins"`      // comma-separated origins allowed to make cross-origin requests, * for any
+	CORSMethods     string   `json:"cors_methods"`      // comma-separated methods allowed cross-origin beyond GET, HEAD and POST
+	CORSHeaders     string   `json:"cors_headers"`      // comma-separated request headers allowed cross-origin
+	CORSCredentials bool     `json:"cors_credentials"`  // allow cross-origin requests with credentials
+	CORSMaxAge      Duration `json:"cors_max_age"`      // how long browsers may cache preflight responses
+	LegacySunset    string   `json:"legacy_sunset"`     // date, as 2006-01-02, when unversioned paths stop being served, if decided
+	AuthKeys        string   `json:"auth_keys"`         // keys file to authenticate clients with, if any
+	AuthReload      Duration `json:"auth_reload"`       // how often the keys file is checked for changes
+	AuthFailures    string   `json:"auth_failures"`     // per-IP limit on failed authentication attempts, see ParseRateLimit
+	TokenTTL        Duration `json:"token_ttl"`         // validity of bearer tokens issued by the token command
+	TraceExporter   string   `json:"trace_exporter"`    // none, stderr or otlp
+	OTLPEndpoint    string   `json:"otlp_endpoint"`     // OTLP/HTTP collector to export traces to
+}
+
+// Default returns the default configuration.
+func Default() Config {
+	return Config{
+		Addr:            ":8080",
+		SWAPIBaseURL:    "https://swapi.dev/api",
+		TopN:            20,
+		CacheTTL:        Duration(time.Hour),
+		RefreshInterval: Duration(50 * time.Minute),
+		Retries:         2,
+		UpstreamTimeout: Duration(10 * time.Second),
+		UpstreamBudget:  Duration(2 * time.Second),
+		ReadTimeout:     Duration(5 * time.Second),
+		WriteTimeout:    Duration(30 * time.Second),
+		IdleTimeout:     Duration(2 * time.Minute),
+		ShutdownTimeout: Duration(15 * time.Second),
+		Metrics:         true,
+		ReadinessGate:   true,
+		LogLevel:        "info",
+		LogFormat:       "text",
+		Compression:     true,
+		CompressMinSize: 1024,
+		CORSMethods:     "DELETE",
+		CORSHeaders:     "Authorization, X-API-Key, X-Request-ID",
+		CORSMaxAge:      Duration(10 * time.Minute),
+		AuthReload:      Duration(10 * time.Second),
+		AuthFailures:    "10/m",
+		TokenTTL:        Duration(24 * time.Hour),
+		TraceExporter:   "none",
+		OTLPEndpoint:    "http://localhost:4318",
+	}
+}
+
+// setting describes a single configuration value. Its name is used as is for
+// the flag, with underscores for the config file key and upper-cased with
+// underscores and EnvPrefix for the environment variable.
+type setting struct {
+	name  string
+	usage string
+	field func(c *Config) any
+}
+
+var settings = []setting{
+	{"addr", "address to listen at", func(c *Config) any { return &c.Addr }},
+	{"swapi-base-url", "base URL of SWAPI", func(c *Config) any { return &c.SWAPIBaseURL }},
+	{"dataset", "snapshot to use instead of SWAPI, as written by export -format snapshot", func(c *Config) any { return &c.Dataset }},
+	{"top-n", "number of characters in rankings and search results", func(c *Config) any { return &c.TopN }},
+	{"cache-ttl", "how long SWAPI data is cached, 0 for indefinitely", func(c *Config) any { return &c.CacheTTL }},
+	{"refresh-interval", "how often the cache is refreshed in the background", func(c *Config) any { return &c.RefreshInterval }},
+	{"retries", "retries of failed SWAPI requests", func(c *Config) any { return &c.Retries }},
+	{"upstream-timeout", "timeout of a single SWAPI request", func(c *Config) any { return &c.UpstreamTimeout }},
+	{"upstream-budget", "time the readiness check waits for SWAPI", func(c *Config) any { return &c.UpstreamBudget }},
+	{"read-timeout", "maximum duration for reading a request", func(c *Config) any { return &c.ReadTimeout }},
+	{"write-timeout", "maximum duration for writing a response", func(c *Config) any { return &c.WriteTimeout }},
+	{"idle-timeout", "maximum time to keep idle connections open", func(c *Config) any { return &c.IdleTimeout }},
+	{"shutdown-timeout", "maximum time to wait for in-flight requests on shutdown", func(c *Config) any { return &c.ShutdownTimeout }},
+	{"metrics", "serve metrics at /metrics", func(c *Config) any { return &c.Metrics }},
+	{"readiness-gate", "reject traffic until the cache is warm", func(c *Config) any { return &c.ReadinessGate }},
+	{"log-level", "log level: debug, info, warn or error", func(c *Config) any { return &c.LogLevel }},
+	{"log-format", "log format: text or json", func(c *Config) any { return &c.LogFormat }},
+	{"rate-limits", "per-client rate limits by route, e.g. \"*=10/s:20,/top-fat-characters=60/m\"; empty for none", func(c *Config) any { return &c.RateLimits }},
+	{"compression", "compress responses if the client accepts it", func(c *Config) any { return &c.Compression }},
+	{"compress-min-size", "minimum size in bytes of responses to compress", func(c *Config) any { return &c.CompressMinSize }},
+	{"cors-origins", "comma-separated origins allowed to make cross-origin requests, * for any; empty to disable CORS", func(c *Config) any { return &c.CORSOrigins }},
+	{"cors-methods", "comma-separated methods allowed cross-origin beyond GET, HEAD and POST", func(c *Config) any { return &c.CORSMethods }},
+	{"cors-headers", "comma-separated request headers allowed cross-origin", func(c *Config) any { return &c.CORSHeaders }},
+	{"cors-credentials", "allow cross-origin requests with credentials", func(c *Config) any { return &c.CORSCredentials }},
+	{"cors-max-age", "how long browsers may cache preflight responses", func(c *Config) any { return &c.CORSMaxAge }},
+	{"legacy-sunset", "date, as 2006-01-02, announced for when the deprecated unversioned paths stop being served", func(c *Config) any { return &c.LegacySunset }},
+	{"auth-keys", "JSON file of API keys and the bearer token secret; empty to allow anonymous access", func(c *Config) any { return &c.AuthKeys }},
+	{"auth-reload", "how often the keys file is checked for changes", func(c *Config) any { return &c.AuthReload }},
+	{"auth-failures", "per-IP limit on failed authentication attempts, e.g. \"10/m\"; empty for none", func(c *Config) any { return &c.AuthFailures }},
+	{"token-ttl", "validity of bearer tokens issued by the token command", func(c *Config) any { return &c.TokenTTL }},
+	{"trace-exporter", "where to export traces: none, stderr or otlp", func(c *Config) any { return &c.TraceExporter }},
+	{"otlp-endpoint", "OTLP/HTTP collector to export traces to, with -trace-exporter otlp", func(c *Config) any { return &c.OTLPEndpoint }},
+}
+
+func (s setting) key() string {
+	return strings.ReplaceAll(s.name, "-", "_")
+}
+
+func (s setting) env() string {
+	return EnvPrefix + strings.ToUpper(s.key())
+}
+
+// Load registers configuration flags on fs, parses args and returns the
+// resulting configuration. The config file is given by the -config flag or
+// the STARWARS_CONFIG environment variable. The caller may register
+// additional flags on fs before calling Load.
+func Load(fs *flag.FlagSet, args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	configFile := fs.String("config", getenv(EnvPrefix+"CONFIG"), "config file (.json, .yaml, .yml or .toml)")
+
+	flagValues := make(map[string]string)
+
+	for _, s := range settings {
+		v := &flagValue{name: s.name, values: flagValues}
+		v.isBool = isBool(s.field(&cfg))
+		v.def = formatValue(s.field(&cfg))
+		fs.Var(v, s.name, s.usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return Config{}, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+
+	if *configFile != "" {
+		if err := cfg.loadFile(*configFile); err != nil {
+			return Config{}, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env()); value != "" {
+			if err := setValue(s.field(&cfg), value); err != nil {
+				return Config{}, fmt.Errorf("invalid value %q for %s: %v", value, s.env(), err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.name]; ok {
+			if err := setValue(s.field(&cfg), value); err != nil {
+				return Config{}, fmt.Errorf("invalid value %q for -%s: %v", value, s.name, err)
+			}
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+// Validate checks that the configuration is usable.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Addr == "" {
+		errs = append(errs, errors.New("addr must not be empty"))
+	}
+
+	if u, err := url.Parse(c.SWAPIBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("swapi_base_url must be an http or https URL, got %q", c.SWAPIBaseURL))
+	}
+
+	if c.TopN < 1 {
+		errs = append(errs, fmt.Errorf("top_n must be at least 1, got %d", c.TopN))
+	}
+
+	if c.CompressMinSize < 0 {
+		errs = append(errs, fmt.Errorf("compress_min_size must not be negative, got %d", c.CompressMinSize))
+	}
+
+	if c.Retries < 0 {
+		errs = append(errs, fmt.Errorf("retries must not be negative, got %d", c.Retries))
+	}
+
+	for _, d := range []struct {
+		key   string
+		value Duration
+	}{
+		{"cache_ttl", c.CacheTTL},
+		{"cors_max_age", c.CORSMaxAge},
+		{"upstream_timeout", c.UpstreamTimeout},
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s must not be negative, got %s", d.key, d.value))
+		}
+	}
+
+	for _, d := range []struct {
+		key   string
+		value Duration
+	}{
+		{"refresh_interval", c.RefreshInterval},
+		{"upstream_budget", c.UpstreamBudget},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"auth_reload", c.AuthReload},
+		{"token_ttl", c.TokenTTL},
+	} {
+		if d.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive, got %s", d.key, d.value))
+		}
+	}
+
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log_level must be debug, info, warn or error, got %q", c.LogLevel))
+	}
+
+	switch strings.ToLower(c.LogFormat) {
+	case "text", "json":
+	default:
+		errs = append(errs, fmt.Errorf("log_format must be text or json, got %q", c.LogFormat))
+	}
+
+	for _, origin := range SplitList(c.CORSOrigins) {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+			errs = append(errs, fmt.Errorf("cors_origins must be * or origins like https://example.com, got %q", origin))
+		}
+	}
+
+	if c.CORSCredentials && slices.Contains(SplitList(c.CORSOrigins), "*") {
+		errs = append(errs, errors.New("cors_credentials cannot be enabled with cors_origins *, as any site could then make requests with credentials; list the allowed origins instead"))
+	}
+
+	if c.LegacySunset != "" {
+		if _, err := time.Parse(time.DateOnly, c.LegacySunset); err != nil {
+			errs = append(errs, fmt.Errorf("legacy_sunset must be a date like 2006-01-02, got %q", c.LegacySunset))
+		}
+	}
+
+	// The routes are checked when serving, by the API serving them.
+	if _, err := ParseRateLimits(c.RateLimits); err != nil {
+		errs = append(errs, fmt.Errorf("rate_limits: %v", err))
+	}
+
+	if c.AuthFailures != "" {
+		if _, err := ParseRateLimit(c.AuthFailures); err != nil {
+			errs = append(errs, fmt.Errorf("auth_failures: %v", err))
+		}
+	}
+
+	switch c.TraceExporter {
+	case "none", "stderr":
+	case "otlp":
+		if u, err := url.Parse(c.OTLPEndpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("otlp_endpoint must be an http or https URL, got %q", c.OTLPEndpoint))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("trace_exporter must be none, stderr or otlp, got %q", c.TraceExporter))
+	}
+
+	if c.CacheTTL > 0 && c.RefreshInterval >= c.CacheTTL {
+		errs = append(errs, fmt.Errorf("refresh_interval (%s) must be shorter than cache_ttl (%s)", c.RefreshInterval, c.CacheTTL))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
+	}
+
+	return nil
+}
+
+// SplitList splits a comma-separated list, dropping surrounding space and
+// empty elements.
+func SplitList(s string) []string {
+	var list []string
+
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+
+	return list
+}
+
+// RateLimit is a token bucket limit, refilled at Rate tokens per second up to
+// Burst tokens.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimits parses a comma-separated list of rate limits by route, each
+// given as route=count/unit[:burst], where unit is s, m or h. The route "*"
+// applies to all routes without limits of their own. The burst defaults to
+// count, so that "*=100/m" allows 100 requests a minute.
+func ParseRateLimits(s string) (map[string]RateLimit, error) {
+	limits := make(map[string]RateLimit)
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		route, spec, ok := strings.Cut(part, "=")
+		if !ok || route == "" || !strings.Contains(spec, "/") {
+			return nil, fmt.Errorf("expected route=count/unit[:burst], got %q", part)
+		}
+
+		limit, err := ParseRateLimit(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := limits[route]; ok {
+			return nil, fmt.Errorf("duplicate route %q", route)
+		}
+
+		limits[route] = limit
+	}
+
+	return limits, nil
+}
+
+// ParseRateLimit parses a rate limit given as count/unit[:burst], where unit is
+// s, m or h. The burst defaults to count.
+func ParseRateLimit(s string) (RateLimit, error) {
+	spec, burstStr, hasBurst := strings.Cut(strings.TrimSpace(s), ":")
+
+	countStr, unit, ok := strings.Cut(spec, "/")
+	if !ok {
+		return RateLimit{}, fmt.Errorf("expected count/unit[:burst], got %q", s)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count < 1 {
+		return RateLimit{}, fmt.Errorf("count must be a positive integer, got %q", countStr)
+	}
+
+	var period time.Duration
+	switch unit {
+	case "s":
+		period = time.Second
+	case "m":
+		period = time.Minute
+	case "h":
+		period = time.Hour
+	default:
+		return RateLimit{}, fmt.Errorf("unit must be s, m or h, got %q", unit)
+	}
+
+	burst := count
+	if hasBurst {
+		if burst, err = strconv.Atoi(burstStr); err != nil || burst < 1 {
+			return RateLimit{}, fmt.Errorf("burst must be a positive integer, got %q", burstStr)
+		}
+	}
+
+	return RateLimit{
+		Rate:  float64(count) / period.Seconds(),
+		Burst: burst,
+	}, nil
+}
+
+// Print writes the configuration to w as indented JSON.
+func (c Config) Print(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+// loadFile applies the settings in the given file. The format is chosen by
+// file extension. YAML and TOML files are limited to flat documents of
+// "key: value" and "key = value" lines respectively, which is all the
+// configuration needs.
+func (c *Config) loadFile(name string) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	var values map[string]string
+
+	switch ext := filepath.Ext(name); ext {
+	case ".json":
+		values, err = parseJSON(b)
+	case ".yaml", ".yml":
+		values, err = parseFlat(b, ":")
+	case ".toml":
+		values, err = parseFlat(b, "=")
+	default:
+		return fmt.Errorf("unknown config file format: %q", ext)
+	}
+	if err != nil {
+		return fmt.Errorf("error parsing config file %s: %v", name, err)
+	}
+
+	byKey := make(map[string]setting)
+	for _, s := range settings {
+		byKey[s.key()] = s
+	}
+
+	for key, value := range values {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("unknown key %q in config file %s", key, name)
+		}
+
+		if err := setValue(s.field(c), value); err != nil {
+			return fmt.Errorf("invalid value %q for %s in config file %s: %v", value, key, name, err)
+		}
+	}
+
+	return nil
+}
+
+func parseJSON(b []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string, len(raw))
+
+	for key, value := range raw {
+		var s string
+		if err := json.Unmarshal(value, &s); err == nil {
+			values[key] = s
+		} else {
+			values[key] = string(value)
+		}
+	}
+
+	return values, nil
+}
+
+// parseFlat parses lines of keys and values separated by sep. Blank lines and
+// lines starting with # are ignored, and values may be quoted.
+func parseFlat(b []byte, sep string) (map[string]string, error) {
+	values := make(map[string]string)
+
+	for n, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key %s value", n+1, sep)
+		}
+
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		if i := strings.Index(value, " #"); i >= 0 && !strings.HasPrefix(value, `"`) && !strings.HasPrefix(value, "'") {
+			value = strings.TrimSpace(value[:i])
+		}
+
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+func setValue(field any, value string) error {
+	switch f := field.(type) {
+	case *string:
+		*f = value
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		*f = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		*f = b
+	case *Duration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.New("not a duration")
+		}
+		*f = Duration(d)
+	default:
+		panic(fmt.Sprintf("config: unsupported field type %T", field))
+	}
+	return nil
+}
+
+func formatValue(field any) string {
+	switch f := field.(type) {
+	case *string:
+		return *f
+	case *int:
+		return strconv.Itoa(*f)
+	case *bool:
+		return strconv.FormatBool(*f)
+	case *Duration:
+		return f.String()
+	default:
+		panic(fmt.Sprintf("config: unsupported field type %T", field))
+	}
+}
+
+func isBool(field any) bool {
+	_, ok := field.(*bool)
+	return ok
+}
+
+// flagValue records the raw value of a flag so that it can be applied on top
+// of the other configuration layers after parsing.
+type flagValue struct {
+	name   string
+	def    string
+	isBool bool
+	values map[string]string
+}
+
+func (v *flagValue) String() string {
+	if v == nil {
+		return ""
+	}
+	if value, ok := v.values[v.name]; ok {
+		return value
+	}
+	return v.def
+}
+
+func (v *flagValue) Set(value string) error {
+	v.values[v.name] = value
+	return nil
+}
+
+func (v *flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+// Duration is a time.Duration represented as a string such as "1h30m" in
+// JSON.
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
@@ -0,0 +1,273 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, args []string, env map[string]string) (Config, error) {
+	t.Helper()
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	return Load(fs, args, func(key string) string { return env[key] })
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		cfg, err := load(t, nil, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := cfg, Default(); got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Layering", func(t *testing.T) {
+		configFile := writeFile(t, "config.json", `
+{
+  "addr": ":1111",
+  "top_n": 5,
+  "cache_ttl": "2h",
+  "metrics": false
+}
+`)
+
+		env := map[string]string{
+			"STARWARS_CONFIG": configFile,
+			"STARWARS_TOP_N":  "10",
+			"STARWARS_ADDR":   ":2222",
+		}
+
+		cfg, err := load(t, []string{"-addr", ":3333", "-readiness-gate=false"}, env)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := Default()
+		want.Addr = ":3333"                     // flag beats env and file
+		want.TopN = 10                          // env beats file
+		want.CacheTTL = Duration(2 * time.Hour) // file beats default
+		want.Metrics = false
+		want.ReadinessGate = false
+
+		if got := cfg; got != want {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Config file flag", func(t *testing.T) {
+		configFile := writeFile(t, "config.json", `{"top_n": 5}`)
+
+		cfg, err := load(t, []string{"-config", configFile}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := cfg.TopN, 5; got != want {
+			t.Errorf("got top_n %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Parse error", func(t *testing.T) {
+		_, err := load(t, []string{"-foo"}, nil)
+
+		if !errors.Is(err, ErrParse) {
+			t.Errorf("error is %v, want %v", err, ErrParse)
+		}
+	})
+
+	t.Run("Help", func(t *testing.T) {
+		_, err := load(t, []string{"-h"}, nil)
+
+		if !errors.Is(err, flag.ErrHelp) {
+			t.Errorf("error is %v, want %v", err, flag.ErrHelp)
+		}
+	})
+
+	t.Run("Invalid values", func(t *testing.T) {
+		for n, tc := range []struct {
+			args    []string
+			env     map[string]string
+			wantErr string
+		}{
+			{[]string{"-top-n", "foo"}, nil, `invalid value "foo" for -top-n: not an integer`},
+			{nil, map[string]string{"STARWARS_CACHE_TTL": "foo"}, `invalid value "foo" for STARWARS_CACHE_TTL: not a duration`},
+			{[]string{"-metrics=foo"}, nil, `invalid value "foo" for -metrics: not a boolean`},
+			{[]string{"-top-n", "0"}, nil, "top_n must be at least 1"},
+			{[]string{"-swapi-base-url", "foo"}, nil, "swapi_base_url must be an http or https URL"},
+			{[]string{"-retries", "-1"}, nil, "retries must not be negative"},
+			{[]string{"-refresh-interval", "2h"}, nil, "refresh_interval (2h0m0s) must be shorter than cache_ttl (1h0m0s)"},
+			{[]string{"-shutdown-timeout", "0s"}, nil, "shutdown_timeout must be positive"},
+			{[]string{"-log-level", "foo"}, nil, `log_level must be debug, info, warn or error, got "foo"`},
+			{[]string{"-log-format", "foo"}, nil, `log_format must be text or json, got "foo"`},
+			{[]string{"-cors-origins", "https://example.com/foo"}, nil, `cors_origins must be * or origins like https://example.com, got "https://example.com/foo"`},
+			{[]string{"-cors-origins", "https://example.com, *", "-cors-credentials"}, nil, "cors_credentials cannot be enabled with cors_origins *"},
+			{[]string{"-legacy-sunset", "tomorrow"}, nil, `legacy_sunset must be a date like 2006-01-02, got "tomorrow"`},
+			{[]string{"-rate-limits", "*=10"}, nil, `rate_limits: expected route=count/unit[:burst], got "*=10"`},
+			{[]string{"-auth-failures", "10"}, nil, `auth_failures: expected count/unit[:burst], got "10"`},
+			{[]string{"-trace-exporter", "foo"}, nil, `trace_exporter must be none, stderr or otlp, got "foo"`},
+			{[]string{"-trace-exporter", "otlp", "-otlp-endpoint", "foo"}, nil, `otlp_endpoint must be an http or https URL, got "foo"`},
+		} {
+			_, err := load(t, tc.args, tc.env)
+
+			if err == nil {
+				t.Errorf("[%d] error is nil", n)
+				continue
+			}
+
+			if got := err.Error(); !strings.Contains(got, tc.wantErr) {
+				t.Errorf("[%d] error is %q, want it to contain %q", n, got, tc.wantErr)
+			}
+		}
+	})
+}
+
+func TestConfig_LoadFile(t *testing.T) {
+	t.Run("Formats", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			content string
+		}{
+			{"config.json", `{"addr": ":1234", "top_n": 5, "cache_ttl": "2h", "metrics": false}`},
+			{"config.yaml", "# comment\naddr: \":1234\"\ntop_n: 5 # inline comment\ncache_ttl: 2h\nmetrics: false\n"},
+			{"config.yml", "addr: ':1234'\ntop_n: 5\ncache_ttl: 2h\nmetrics: false\n"},
+			{"config.toml", "# comment\naddr = \":1234\"\ntop_n = 5\ncache_ttl = \"2h\"\nmetrics = false\n"},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				cfg := Default()
+
+				if err := cfg.loadFile(writeFile(t, tc.name, tc.content)); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				want := Default()
+				want.Addr = ":1234"
+				want.TopN = 5
+				want.CacheTTL = Duration(2 * time.Hour)
+				want.Metrics = false
+
+				if got := cfg; got != want {
+					t.Errorf("got %+v, want %+v", got, want)
+				}
+			})
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		for n, tc := range []struct {
+			name    string
+			content string
+			wantErr string
+		}{
+			{"config.json", `{"foo": 1}`, `unknown key "foo"`},
+			{"config.json", `{`, "error parsing config file"},
+			{"config.yaml", "addr", "line 1: expected key : value"},
+			{"config.toml", "top_n = foo", `invalid value "foo" for top_n`},
+			{"config.ini", "", `unknown config file format: ".ini"`},
+		} {
+			cfg := Default()
+
+			err := cfg.loadFile(writeFile(t, tc.name, tc.content))
+
+			if err == nil {
+				t.Errorf("[%d] error is nil", n)
+				continue
+			}
+
+			if got := err.Error(); !strings.Contains(got, tc.wantErr) {
+				t.Errorf("[%d] error is %q, want it to contain %q", n, got, tc.wantErr)
+			}
+		}
+	})
+}
+
+func TestParseRateLimits(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		limits, err := ParseRateLimits(" *=10/s:20, /top-fat-characters=60/m,/readyz=1/h ")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(limits), "map[*:{10 20} /readyz:{0.0002777777777777778 1} /top-fat-characters:{1 60}]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		limits, err := ParseRateLimits("")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := len(limits), 0; got != want {
+			t.Errorf("got %d limits, want %d", got, want)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for n, tc := range []struct {
+			s       string
+			wantErr string
+		}{
+			{"10/s", `expected route=count/unit[:burst], got "10/s"`},
+			{"=10/s", `expected route=count/unit[:burst], got "=10/s"`},
+			{"*=0/s", `count must be a positive integer, got "0"`},
+			{"*=10/d", `unit must be s, m or h, got "d"`},
+			{"*=10/s:0", `burst must be a positive integer, got "0"`},
+			{"*=10/s,*=20/s", `duplicate route "*"`},
+		} {
+			_, err := ParseRateLimits(tc.s)
+
+			if err == nil {
+				t.Errorf("[%d] error is nil", n)
+				continue
+			}
+
+			if got, want := err.Error(), tc.wantErr; got != want {
+				t.Errorf("[%d] error is %q, want %q", n, got, want)
+			}
+		}
+	})
+}
+
+func TestConfig_Print(t *testing.T) {
+	var buf strings.Builder
+
+	if err := Default().Print(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		`"addr": ":8080"`,
+		`"top_n": 20`,
+		`"cache_ttl": "1h0m0s"`,
+		`"metrics": true`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+}
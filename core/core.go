@@ -1,40 +1,195 @@
 package core
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"sort"
-	"strconv"
+	"strings"
 
 	"github.com/jsageryd/starwars-coding-test/starwars"
 	"github.com/jsageryd/starwars-coding-test/swapi"
+	"github.com/jsageryd/starwars-coding-test/tracing"
 )
 
+// ErrNotFound is returned when a requested character does not exist.
+var ErrNotFound = fmt.Errorf("character %w", starwars.ErrNotFound)
+
 type Core struct {
 	swapiClient *swapi.Client
+	limit       int
+}
+
+type Option func(*Core)
+
+// WithLimit sets the number of characters returned by the rankings and
+// searches. The default is 20.
+func WithLimit(n int) Option {
+	return func(c *Core) {
+		c.limit = n
+	}
 }
 
-func New(client *swapi.Client) *Core {
-	return &Core{
+func New(client *swapi.Client, opts ...Option) *Core {
+	c := &Core{
 		swapiClient: client,
+		limit:       20,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
-func (c *Core) TopFatCharacters() ([]starwars.Character, error) {
-	characters, err := c.swapiClient.People()
+// Characters returns all characters.
+func (c *Core) Characters(ctx context.Context) ([]starwars.Character, error) {
+	ctx, span := tracing.Start(ctx, "core.Characters", tracing.KindInternal)
+	defer span.End()
+
+	characters, err := c.swapiClient.People(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching characters from SWAPI: %v", err)
 	}
 
-	return topFat(characters, 20), nil
+	return characters, nil
+}
+
+// Character returns the character with the given name, compared without
+// regard to case, or ErrNotFound if there is none.
+func (c *Core) Character(ctx context.Context, name string) (starwars.Character, error) {
+	ctx, span := tracing.Start(ctx, "core.Character", tracing.KindInternal)
+	defer span.End()
+
+	characters, err := c.Characters(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return starwars.Character{}, err
+	}
+
+	for _, character := range characters {
+		if strings.EqualFold(character.Name, name) {
+			return character, nil
+		}
+	}
+
+	return starwars.Character{}, ErrNotFound
 }
 
-func (c *Core) TopOldCharacters() ([]starwars.Character, error) {
-	characters, err := c.swapiClient.People()
+// Entities returns all entities of the given kind, one of starwars.Kinds.
+func (c *Core) Entities(ctx context.Context, kind string) ([]starwars.Entity, error) {
+	ctx, span := tracing.Start(ctx, "core.Entities", tracing.KindInternal, tracing.Attr("kind", kind))
+	defer span.End()
+
+	entities, err := c.swapiClient.Resources(ctx, kind)
 	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("error fetching %s from SWAPI: %w", kind, err)
+	}
+
+	return entities, nil
+}
+
+// Search returns the characters best matching query, most relevant first. If
+// the characters are cached they are searched by name and appearance,
+// tolerating typos. Otherwise, rather than fetching all characters, SWAPI is
+// searched, which matches names only and in SWAPI order.
+func (c *Core) Search(ctx context.Context, query string) ([]starwars.Character, error) {
+	ctx, span := tracing.Start(ctx, "core.Search", tracing.KindInternal)
+	defer span.End()
+
+	var characters []starwars.Character
+
+	if index, ok := c.swapiClient.CachedIndex(); ok {
+		for _, r := range index.Search(query, c.limit) {
+			characters = append(characters, r.Character)
+		}
+	} else {
+		// SWAPI returns every character for an empty query
+		if strings.TrimSpace(query) == "" {
+			return nil, nil
+		}
+
+		var err error
+
+		characters, err = c.swapiClient.SearchPeople(ctx, query)
+		if err != nil {
+			span.RecordError(err)
+			return nil, fmt.Errorf("error searching characters in SWAPI: %v", err)
+		}
+
+		characters = characters[:min(len(characters), c.limit)]
+	}
+
+	span.SetAttributes(tracing.Attr("results", len(characters)))
+
+	return characters, nil
+}
+
+func (c *Core) TopFatCharacters(ctx context.Context) ([]starwars.Character, error) {
+	ctx, span := tracing.Start(ctx, "core.TopFatCharacters", tracing.KindInternal)
+	defer span.End()
+
+	characters, err := c.swapiClient.People(ctx)
+	if err != nil {
+		span.RecordError(err)
 		return nil, fmt.Errorf("error fetching characters from SWAPI: %v", err)
 	}
 
-	return topOld(characters, 20), nil
+	_, sortSpan := tracing.Start(ctx, "core.topFat", tracing.KindInternal, tracing.Attr("characters", len(characters)))
+	top := topFat(characters, c.limit)
+	sortSpan.End()
+
+	slog.DebugContext(ctx, "Ranked characters by BMI", "characters", len(characters), "top", len(top))
+
+	return top, nil
+}
+
+func (c *Core) TopOldCharacters(ctx context.Context) ([]starwars.Character, error) {
+	ctx, span := tracing.Start(ctx, "core.TopOldCharacters", tracing.KindInternal)
+	defer span.End()
+
+	characters, err := c.swapiClient.People(ctx)
+	if err != nil {
+		span.RecordError(err)
+		return nil, fmt.Errorf("error fetching characters from SWAPI: %v", err)
+	}
+
+	_, sortSpan := tracing.Start(ctx, "core.topOld", tracing.KindInternal, tracing.Attr("characters", len(characters)))
+	top := topOld(characters, c.limit)
+	sortSpan.End()
+
+	slog.DebugContext(ctx, "Ranked characters by birth year", "characters", len(characters), "top", len(top))
+
+	return top, nil
+}
+
+func (c *Core) CacheStatus() starwars.CacheStatus {
+	return c.swapiClient.CacheStatus()
+}
+
+// Ping checks that SWAPI is reachable.
+func (c *Core) Ping(ctx context.Context) error {
+	return c.swapiClient.Ping(ctx)
+}
+
+// PurgeCache drops the cached characters.
+func (c *Core) PurgeCache() {
+	c.swapiClient.Purge()
+}
+
+// RefreshCache fetches all characters from SWAPI and updates the cache.
+func (c *Core) RefreshCache(ctx context.Context) error {
+	ctx, span := tracing.Start(ctx, "core.RefreshCache", tracing.KindInternal)
+	defer span.End()
+
+	if err := c.swapiClient.Refresh(ctx); err != nil {
+		span.RecordError(err)
+		return fmt.Errorf("error refreshing characters from SWAPI: %v", err)
+	}
+
+	return nil
 }
 
 // topFat returns the top N fattest characters according to their BMI.
@@ -85,24 +240,5 @@ func topOld(cs []starwars.Character, n int) []starwars.Character {
 }
 
 func numericBirthYear(year string) (float64, error) {
-	if len(year) < 4 {
-		return 0, fmt.Errorf("unknown birth year format: %s", year)
-	}
-
-	numberStr := year[:len(year)-3]
-	suffix := year[len(year)-3:]
-
-	number, err := strconv.ParseFloat(numberStr, 64)
-	if err != nil {
-		return 0, fmt.Errorf("unknown birth year format: %s", year)
-	}
-
-	switch suffix {
-	case "BBY":
-		return -number, nil
-	case "ABY":
-		return number, nil
-	default:
-		return 0, fmt.Errorf("unknown birth year format: %s", year)
-	}
+	return starwars.ParseBirthYear(year)
 }
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,8 +10,241 @@ import (
 
 	"github.com/jsageryd/starwars-coding-test/starwars"
 	"github.com/jsageryd/starwars-coding-test/swapi"
+	"github.com/jsageryd/starwars-coding-test/tracing"
 )
 
+func TestCore_Character(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`
+{
+  "results": [
+    {"name":"Luke Skywalker","height":"172","mass":"77"},
+    {"name":"R2-D2","height":"96","mass":"32"}
+  ]
+}
+`))
+		},
+	))
+
+	c := New(
+		swapi.NewClient(ts.URL),
+	)
+
+	t.Run("Success", func(t *testing.T) {
+		gotCharacter, err := c.Character(context.Background(), "luke skywalker")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wantCharacter := starwars.Character{Name: "Luke Skywalker", Height: 172, Mass: 77}
+
+		if fmt.Sprint(gotCharacter) != fmt.Sprint(wantCharacter) {
+			t.Errorf("got %v, want %v", gotCharacter, wantCharacter)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := c.Character(context.Background(), "Jar Jar Binks")
+
+		if got, want := err, ErrNotFound; got != want {
+			t.Errorf("error is %v, want %v", got, want)
+		}
+	})
+}
+
+func TestCore_Search(t *testing.T) {
+	var gotReqCount int
+
+	// Serves characters like SWAPI, with names matching ?search if given
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			gotReqCount++
+
+			people := []string{
+				`{"name":"Luke Skywalker","height":"172","mass":"77"}`,
+				`{"name":"Anakin Skywalker","height":"188","mass":"84"}`,
+				`{"name":"Shmi Skywalker","height":"163","mass":"unknown"}`,
+				`{"name":"R2-D2","height":"96","mass":"32"}`,
+				`{"name":"Jabba Desilijic Tiure","height":"175","mass":"1,358"}`,
+			}
+
+			query := strings.ToLower(r.URL.Query().Get("search"))
+
+			var results []string
+			for _, p := range people {
+				if strings.Contains(strings.ToLower(p), query) {
+					results = append(results, p)
+				}
+			}
+
+			w.Write([]byte(`{"results": [` + strings.Join(results, ",") + `]}`))
+		},
+	))
+
+	warm := func(t *testing.T, c *Core) {
+		t.Helper()
+
+		if _, err := c.swapiClient.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		c := New(
+			swapi.NewClient(ts.URL),
+		)
+
+		warm(t, c)
+
+		gotCharacters, err := c.Search(context.Background(), "Luke Skywalkr")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wantCharacters := []starwars.Character{
+			{Name: "Luke Skywalker", Height: 172, Mass: 77},
+		}
+
+		if fmt.Sprint(gotCharacters) != fmt.Sprint(wantCharacters) {
+			t.Errorf("got %v, want %v", gotCharacters, wantCharacters)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		c := New(
+			swapi.NewClient(ts.URL),
+			WithLimit(2),
+		)
+
+		warm(t, c)
+
+		gotCharacters, err := c.Search(context.Background(), "skywalker")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wantCharacters := []starwars.Character{
+			{Name: "Anakin Skywalker", Height: 188, Mass: 84},
+			{Name: "Luke Skywalker", Height: 172, Mass: 77},
+		}
+
+		if fmt.Sprint(gotCharacters) != fmt.Sprint(wantCharacters) {
+			t.Errorf("got %v, want %v", gotCharacters, wantCharacters)
+		}
+	})
+
+	t.Run("Cold cache", func(t *testing.T) {
+		for _, tc := range []struct {
+			query          string
+			limit          int
+			wantCharacters []starwars.Character
+			wantReqCount   int
+		}{
+			{
+				query: "skywalker",
+				limit: 10,
+				wantCharacters: []starwars.Character{
+					{Name: "Luke Skywalker", Height: 172, Mass: 77},
+					{Name: "Anakin Skywalker", Height: 188, Mass: 84},
+					{Name: "Shmi Skywalker", Height: 163},
+				},
+				wantReqCount: 1,
+			},
+			{
+				query: "skywalker",
+				limit: 2,
+				wantCharacters: []starwars.Character{
+					{Name: "Luke Skywalker", Height: 172, Mass: 77},
+					{Name: "Anakin Skywalker", Height: 188, Mass: 84},
+				},
+				wantReqCount: 1,
+			},
+			{
+				query: "jabba",
+				limit: 10,
+				wantCharacters: []starwars.Character{
+					{Name: "Jabba Desilijic Tiure", Height: 175, Mass: 1358},
+				},
+				wantReqCount: 1,
+			},
+			{
+				query:        " ",
+				limit:        10,
+				wantReqCount: 0,
+			},
+		} {
+			t.Run(fmt.Sprintf("%q limit %d", tc.query, tc.limit), func(t *testing.T) {
+				gotReqCount = 0
+
+				c := New(
+					swapi.NewClient(ts.URL),
+					WithLimit(tc.limit),
+				)
+
+				gotCharacters, err := c.Search(context.Background(), tc.query)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if fmt.Sprint(gotCharacters) != fmt.Sprint(tc.wantCharacters) {
+					t.Errorf("got %v, want %v", gotCharacters, tc.wantCharacters)
+				}
+
+				if got, want := gotReqCount, tc.wantReqCount; got != want {
+					t.Errorf("sent %d requests, want %d", got, want)
+				}
+			})
+		}
+	})
+
+	t.Run("Same measures cold and warm", func(t *testing.T) {
+		c := New(
+			swapi.NewClient(ts.URL),
+		)
+
+		gotCold, err := c.Search(context.Background(), "Shmi")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		warm(t, c)
+
+		gotWarm, err := c.Search(context.Background(), "Shmi")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(gotCold), fmt.Sprint(gotWarm); got != want {
+			t.Errorf("got %s cold, %s warm", got, want)
+		}
+	})
+
+	t.Run("Non-OK response from API", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+		))
+
+		c := New(
+			swapi.NewClient(ts.URL),
+		)
+
+		_, err := c.Search(context.Background(), "luke")
+
+		if err == nil {
+			t.Fatal("error is nil")
+		}
+
+		wantErrStr := "error searching characters in SWAPI: SWAPI returned HTTP 418"
+
+		if got, want := err.Error(), wantErrStr; got != want {
+			t.Errorf("err is %q, want %q", got, want)
+		}
+	})
+}
+
 func TestCore_TopFatCharacters(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(
@@ -31,7 +265,7 @@ func TestCore_TopFatCharacters(t *testing.T) {
 			swapi.NewClient(ts.URL),
 		)
 
-		gotCharacters, err := c.TopFatCharacters()
+		gotCharacters, err := c.TopFatCharacters(context.Background())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -47,6 +281,41 @@ func TestCore_TopFatCharacters(t *testing.T) {
 		}
 	})
 
+	t.Run("Limit", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`
+{
+  "results": [
+    {"name":"Luke Skywalker","height":"172","mass":"77"},
+    {"name":"R2-D2","height":"96","mass":"32"},
+    {"name":"C-3PO","height":"167","mass":"75"}
+  ]
+}
+`))
+			},
+		))
+
+		c := New(
+			swapi.NewClient(ts.URL),
+			WithLimit(2),
+		)
+
+		gotCharacters, err := c.TopFatCharacters(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wantCharacters := []starwars.Character{
+			{Name: "R2-D2", Height: 96, Mass: 32},
+			{Name: "C-3PO", Height: 167, Mass: 75},
+		}
+
+		if fmt.Sprint(gotCharacters) != fmt.Sprint(wantCharacters) {
+			t.Errorf("got %v, want %v", gotCharacters, wantCharacters)
+		}
+	})
+
 	t.Run("Non-OK response from API", func(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
@@ -58,7 +327,7 @@ func TestCore_TopFatCharacters(t *testing.T) {
 			swapi.NewClient(ts.URL),
 		)
 
-		_, err := c.TopFatCharacters()
+		_, err := c.TopFatCharacters(context.Background())
 
 		if err == nil {
 			t.Fatal("error is nil")
@@ -92,7 +361,7 @@ func TestCore_TopOldCharacters(t *testing.T) {
 			swapi.NewClient(ts.URL),
 		)
 
-		gotCharacters, err := c.TopOldCharacters()
+		gotCharacters, err := c.TopOldCharacters(context.Background())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -118,7 +387,7 @@ func TestCore_TopOldCharacters(t *testing.T) {
 			swapi.NewClient(ts.URL),
 		)
 
-		_, err := c.TopOldCharacters()
+		_, err := c.TopOldCharacters(context.Background())
 
 		if err == nil {
 			t.Fatal("error is nil")
@@ -261,3 +530,42 @@ func TestNumericBirthYear(t *testing.T) {
 		}
 	})
 }
+
+func TestCore_Tracing(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+
+	defer tracing.SetDefault(tracing.Default())
+	tracing.SetDefault(tracing.NewTracer(exporter))
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"results": [{"name":"Yoda","birth_year":"896BBY"}]}`))
+		},
+	))
+	defer ts.Close()
+
+	c := New(swapi.NewClient(ts.URL))
+
+	if _, err := c.TopOldCharacters(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.Spans()
+
+	var names []string
+	for _, span := range spans {
+		names = append(names, span.Name)
+	}
+
+	if got, want := fmt.Sprint(names), "[GET people core.topOld core.TopOldCharacters]"; got != want {
+		t.Fatalf("got spans %s, want %s", got, want)
+	}
+
+	root := spans[2].SpanContext
+
+	for _, span := range spans[:2] {
+		if got, want := span.Parent, root; got != want {
+			t.Errorf("got parent %v of %s, want %v", got, span.Name, want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Package dataset reads and writes snapshots of the characters fetched from
+// SWAPI, so that they can later be used as the data source instead of SWAPI.
+package dataset
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// SchemaVersion is the version of the snapshot format written by this
+// package. Snapshots with a newer version cannot be read. Version 2 added the
+// appearance of characters and their references to other entities.
+const SchemaVersion = 2
+
+// Snapshot is a versioned archive of characters. It is stored as JSON, with a
+// checksum of the characters to detect corruption.
+type Snapshot struct {
+	SchemaVersion int                  `json:"schema_version"`
+	CreatedAt     time.Time            `json:"created_at"`
+	Checksum      string               `json:"checksum"` // "sha256:<hex>" of the characters in JSON
+	Characters    []starwars.Character `json:"characters"`
+}
+
+// New returns a snapshot of the given characters.
+func New(cs []starwars.Character, createdAt time.Time) (*Snapshot, error) {
+	if cs == nil {
+		cs = []starwars.Character{}
+	}
+
+	checksum, err := checksum(cs)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		SchemaVersion: SchemaVersion,
+		CreatedAt:     createdAt.UTC(),
+		Checksum:      checksum,
+		Characters:    cs,
+	}, nil
+}
+
+// Write writes the snapshot to w.
+func (s *Snapshot) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// Read reads a snapshot from r, verifying its schema version and checksum.
+func Read(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("error decoding snapshot: %v", err)
+	}
+
+	if s.SchemaVersion < 1 || s.SchemaVersion > SchemaVersion {
+		return nil, fmt.Errorf("unsupported snapshot schema version %d, want at most %d", s.SchemaVersion, SchemaVersion)
+	}
+
+	if s.Characters == nil {
+		s.Characters = []starwars.Character{}
+	}
+
+	checksum, err := checksum(s.Characters)
+	if err != nil {
+		return nil, err
+	}
+
+	if checksum != s.Checksum {
+		return nil, fmt.Errorf("snapshot checksum mismatch: got %s, want %s", checksum, s.Checksum)
+	}
+
+	return &s, nil
+}
+
+// ReadFile reads a snapshot from the named file.
+func ReadFile(name string) (*Snapshot, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("error opening snapshot: %v", err)
+	}
+	defer f.Close()
+
+	return Read(f)
+}
+
+func checksum(cs []starwars.Character) (string, error) {
+	b, err := json.Marshal(cs)
+	if err != nil {
+		return "", fmt.Errorf("error encoding characters: %v", err)
+	}
+
+	sum := sha256.Sum256(b)
+
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,98 @@
+package dataset
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+func TestSnapshot_RoundTrip(t *testing.T) {
+	characters := []starwars.Character{
+		{Name: "Luke Skywalker", Height: 172, Mass: 77, BirthYear: "19BBY"},
+		{Name: "Jabba Desilijic Tiure", Height: 175, Mass: 1358.5, BirthYear: "600BBY"},
+		{Name: "Arvel Crynyd"},
+	}
+
+	createdAt := time.Date(2023, 6, 13, 9, 0, 0, 0, time.UTC)
+
+	s, err := New(characters, createdAt)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	if err := s.Write(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := Read(&buf)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := got.SchemaVersion, SchemaVersion; got != want {
+		t.Errorf("got schema version %d, want %d", got, want)
+	}
+
+	if got, want := got.CreatedAt, createdAt; !got.Equal(want) {
+		t.Errorf("got created at %v, want %v", got, want)
+	}
+
+	if got, want := got.Checksum, s.Checksum; got != want {
+		t.Errorf("got checksum %q, want %q", got, want)
+	}
+
+	if fmt.Sprint(got.Characters) != fmt.Sprint(characters) {
+		t.Errorf("got %v, want %v", got.Characters, characters)
+	}
+}
+
+func TestRead(t *testing.T) {
+	s, err := New([]starwars.Character{{Name: "R2-D2", Height: 96, Mass: 32}}, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var buf bytes.Buffer
+
+	s.Write(&buf)
+
+	valid := buf.String()
+
+	for n, tc := range []struct {
+		input   string
+		wantErr string
+	}{
+		{"foo", "error decoding snapshot"},
+		{strings.Replace(valid, `"schema_version": 2`, `"schema_version": 3`, 1), "unsupported snapshot schema version 3"},
+		{strings.Replace(valid, `"schema_version": 2`, `"schema_version": 0`, 1), "unsupported snapshot schema version 0"},
+		{strings.Replace(valid, `"R2-D2"`, `"R3-D3"`, 1), "snapshot checksum mismatch"},
+	} {
+		_, err := Read(strings.NewReader(tc.input))
+
+		if err == nil {
+			t.Errorf("[%d] error is nil", n)
+			continue
+		}
+
+		if got := err.Error(); !strings.Contains(got, tc.wantErr) {
+			t.Errorf("[%d] error is %q, want it to contain %q", n, got, tc.wantErr)
+		}
+	}
+
+	t.Run("Version 1", func(t *testing.T) {
+		s, err := Read(strings.NewReader(strings.Replace(valid, `"schema_version": 2`, `"schema_version": 1`, 1)))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := s.Characters[0].Name, "R2-D2"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+}
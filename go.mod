@@ -1,3 +1,3 @@
 module github.com/jsageryd/starwars-coding-test
 
-go 1.20
+go 1.21
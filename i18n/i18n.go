@@ -0,0 +1,184 @@
+// Package i18n provides message catalogs and number formatting for the
+// languages the UI is available in.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Fallback is the tag of the locale used when no other matches, and for
+// messages missing from a catalog.
+const Fallback = "en"
+
+//go:embed locales/*.json
+var catalogFiles embed.FS
+
+var locales = make(map[string]*Locale)
+
+func init() {
+	if err := loadCatalogs(catalogFiles); err != nil {
+		log.Fatalf("Error loading message catalogs: %v", err)
+	}
+}
+
+// catalog is the format of the message catalog of a locale, stored as
+// locales/<tag>.json.
+type catalog struct {
+	Name             string            `json:"name"`              // name of the language in itself
+	DecimalSeparator string            `json:"decimal_separator"` // e.g. "."
+	GroupSeparator   string            `json:"group_separator"`   // separates thousands, e.g. ","
+	Messages         map[string]string `json:"messages"`          // fmt formats by key
+}
+
+func loadCatalogs(fsys fs.FS) error {
+	names, err := fs.Glob(fsys, "locales/*.json")
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		var c catalog
+		if err := json.Unmarshal(b, &c); err != nil {
+			return fmt.Errorf("error parsing %s: %v", name, err)
+		}
+
+		tag := strings.TrimSuffix(path.Base(name), ".json")
+
+		locales[tag] = &Locale{
+			Tag:      tag,
+			Name:     c.Name,
+			decimal:  c.DecimalSeparator,
+			group:    c.GroupSeparator,
+			messages: c.Messages,
+		}
+	}
+
+	fallback, ok := locales[Fallback]
+	if !ok {
+		return fmt.Errorf("no catalog for the fallback locale %q", Fallback)
+	}
+
+	for _, l := range locales {
+		if l != fallback {
+			l.fallback = fallback
+		}
+	}
+
+	return nil
+}
+
+// Locale holds the messages and number format of a language.
+type Locale struct {
+	Tag      string // language tag, e.g. "en"
+	Name     string // name of the language in itself, e.g. "English"
+	decimal  string
+	group    string
+	messages map[string]string
+	fallback *Locale
+}
+
+// Locales returns all locales, ordered by tag.
+func Locales() []*Locale {
+	ls := make([]*Locale, 0, len(locales))
+
+	for _, l := range locales {
+		ls = append(ls, l)
+	}
+
+	sort.Slice(ls, func(i, j int) bool {
+		return ls[i].Tag < ls[j].Tag
+	})
+
+	return ls
+}
+
+// Lookup returns the locale of the given language tag, matching on the
+// primary language only, so that "sv-FI" gives "sv".
+func Lookup(tag string) (*Locale, bool) {
+	primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+	l, ok := locales[primary]
+	return l, ok
+}
+
+// Negotiate returns the locale best matching the Accept-Language header value
+// acceptLanguage, or the fallback locale if none matches.
+func Negotiate(acceptLanguage string) *Locale {
+	best, bestQ := locales[Fallback], 0.0
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		if l, ok := Lookup(tag); ok && q > bestQ {
+			best, bestQ = l, q
+		}
+	}
+
+	return best
+}
+
+// T returns the message with the given key formatted with args, taken from the
+// fallback locale if missing from l, or the key itself if missing from both.
+func (l *Locale) T(key string, args ...any) string {
+	for loc := l; loc != nil; loc = loc.fallback {
+		if format, ok := loc.messages[key]; ok {
+			if len(args) == 0 {
+				return format
+			}
+			return fmt.Sprintf(format, args...)
+		}
+	}
+
+	return key
+}
+
+// Number formats v with the given number of decimals, or as few as needed if
+// decimals is negative, using the separators of l.
+func (l *Locale) Number(v float64, decimals int) string {
+	s := strconv.FormatFloat(v, 'f', decimals, 64)
+
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+
+	integer, fraction, hasFraction := strings.Cut(s, ".")
+
+	var b strings.Builder
+
+	b.WriteString(sign)
+
+	for i, digit := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(l.group)
+		}
+		b.WriteRune(digit)
+	}
+
+	if hasFraction {
+		b.WriteString(l.decimal)
+		b.WriteString(fraction)
+	}
+
+	return b.String()
+}
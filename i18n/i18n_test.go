@@ -0,0 +1,119 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestCatalogs(t *testing.T) {
+	fallback, ok := Lookup(Fallback)
+	if !ok {
+		t.Fatalf("no fallback locale %q", Fallback)
+	}
+
+	for _, l := range Locales() {
+		for key := range fallback.messages {
+			if _, ok := l.messages[key]; !ok {
+				t.Errorf("%s: missing message %q", l.Tag, key)
+			}
+		}
+
+		var extra []string
+		for key := range l.messages {
+			if _, ok := fallback.messages[key]; !ok {
+				extra = append(extra, key)
+			}
+		}
+		sort.Strings(extra)
+
+		if len(extra) > 0 {
+			t.Errorf("%s: messages not in the fallback locale: %v", l.Tag, extra)
+		}
+
+		if l.Name == "" || l.decimal == "" || l.group == "" {
+			t.Errorf("%s: incomplete catalog", l.Tag)
+		}
+	}
+}
+
+func TestLocales(t *testing.T) {
+	var tags []string
+	for _, l := range Locales() {
+		tags = append(tags, l.Tag)
+	}
+
+	if got, want := fmt.Sprint(tags), "[de en sv]"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for _, tc := range []struct {
+		acceptLanguage string
+		want           string
+	}{
+		{"", "en"},
+		{"sv", "sv"},
+		{"sv-SE,sv;q=0.9,en;q=0.8", "sv"},
+		{"fr-FR, de;q=0.7, en;q=0.5", "de"},
+		{"en;q=0.5, de;q=0.9", "de"},
+		{"fr", "en"},
+		{"de;q=foo, sv;q=0.1", "sv"},
+		{"*", "en"},
+	} {
+		if got := Negotiate(tc.acceptLanguage).Tag; got != tc.want {
+			t.Errorf("Negotiate(%q) = %s, want %s", tc.acceptLanguage, got, tc.want)
+		}
+	}
+}
+
+func TestLocale_T(t *testing.T) {
+	de, _ := Lookup("de")
+
+	t.Run("Success", func(t *testing.T) {
+		if got, want := de.T("fattest_heading", 20), "Die 20 dicksten Star-Wars-Figuren nach BMI"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		de := &Locale{Tag: "de", messages: map[string]string{}, fallback: locales[Fallback]}
+
+		if got, want := de.T("no_matches"), "No matching characters."; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		if got, want := de.T("foo"), "foo"; got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+}
+
+func TestLocale_Number(t *testing.T) {
+	en, _ := Lookup("en")
+	de, _ := Lookup("de")
+	sv, _ := Lookup("sv")
+
+	for n, tc := range []struct {
+		l        *Locale
+		v        float64
+		decimals int
+		want     string
+	}{
+		{en, 172, -1, "172"},
+		{en, 1358, -1, "1,358"},
+		{en, 78.2, -1, "78.2"},
+		{en, 26.027, 1, "26.0"},
+		{en, -1234567.5, -1, "-1,234,567.5"},
+		{de, 1358, -1, "1.358"},
+		{de, 78.2, -1, "78,2"},
+		{sv, 1358.25, 1, "1\u00a0358,2"},
+	} {
+		if got := tc.l.Number(tc.v, tc.decimals); got != tc.want {
+			t.Errorf("[%d] got %q, want %q", n, got, tc.want)
+		}
+	}
+}
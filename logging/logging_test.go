@@ -0,0 +1,96 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("JSON with request ID", func(t *testing.T) {
+		var buf strings.Builder
+
+		logger, err := New(&buf, "info", "json")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		ctx := WithRequestID(context.Background(), "foo-id")
+
+		logger.With("component", "test").InfoContext(ctx, "Foo happened", "bar", 1)
+		logger.DebugContext(ctx, "Not logged at info level")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+		if got, want := len(lines), 1; got != want {
+			t.Fatalf("got %d lines, want %d:\n%s", got, want, buf.String())
+		}
+
+		var record map[string]any
+
+		if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+			t.Fatalf("error decoding log line: %v", err)
+		}
+
+		for key, want := range map[string]any{
+			"level":      "INFO",
+			"msg":        "Foo happened",
+			"request_id": "foo-id",
+			"component":  "test",
+			"bar":        float64(1),
+		} {
+			if got := record[key]; got != want {
+				t.Errorf("got %s %v, want %v", key, got, want)
+			}
+		}
+	})
+
+	t.Run("Text without request ID", func(t *testing.T) {
+		var buf strings.Builder
+
+		logger, err := New(&buf, "DEBUG", "text")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		logger.DebugContext(context.Background(), "Foo happened")
+
+		if got, want := buf.String(), "level=DEBUG msg=\"Foo happened\"\n"; !strings.HasSuffix(got, want) {
+			t.Errorf("got %q, want suffix %q", got, want)
+		}
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		if _, err := New(nil, "foo", "text"); err == nil {
+			t.Error("unknown level: error is nil")
+		}
+
+		if _, err := New(nil, "info", "foo"); err == nil {
+			t.Error("unknown format: error is nil")
+		}
+	})
+}
+
+func TestRequestID(t *testing.T) {
+	if got := RequestID(context.Background()); got != "" {
+		t.Errorf("got request ID %q from empty context, want none", got)
+	}
+
+	id := NewRequestID()
+
+	if got, want := len(id), 32; got != want {
+		t.Errorf("got request ID of length %d, want %d", got, want)
+	}
+
+	if id == NewRequestID() {
+		t.Error("request IDs are not unique")
+	}
+
+	if got, want := RequestID(WithRequestID(context.Background(), id)), id; got != want {
+		t.Errorf("got request ID %q, want %q", got, want)
+	}
+}
+
+var _ slog.Handler = contextHandler{}
@@ -1,37 +1,17 @@
 package main
 
 import (
-	"log"
-	"net/http"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
 
-	"github.com/jsageryd/starwars-coding-test/api"
-	"github.com/jsageryd/starwars-coding-test/core"
-	"github.com/jsageryd/starwars-coding-test/swapi"
+	"github.com/jsageryd/starwars-coding-test/cli"
 )
 
 func main() {
-	mux := http.NewServeMux()
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	swapiClient := swapi.NewClient("https://swapi.dev/api")
-
-	api.New(
-		core.New(
-			swapiClient,
-		),
-	).Register(mux)
-
-	go func() {
-		log.Printf("Warming up cache...")
-		if _, err := swapiClient.People(); err != nil {
-			log.Printf("Error warming up cache: %v", err)
-		} else {
-			log.Printf("Cache warm")
-		}
-	}()
-
-	addr := ":8080"
-
-	log.Printf("Listening at %s...", addr)
-
-	http.ListenAndServe(addr, mux)
+	os.Exit(cli.Run(ctx, os.Args[1:], os.Stdout, os.Stderr, os.Getenv))
 }
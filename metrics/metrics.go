@@ -0,0 +1,347 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the default histogram buckets, suitable for request
+// durations in seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds a set of metrics and renders them for scraping.
+type Registry struct {
+	mu         sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+func (r *Registry) register(name string, c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Counter registers and returns a counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec[float64](name, help, labels)}
+	r.register(name, c)
+	return c
+}
+
+// Gauge registers and returns a gauge with the given label names.
+func (r *Registry) Gauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec[float64](name, help, labels)}
+	r.register(name, g)
+	return g
+}
+
+// GaugeFunc registers a gauge without labels whose value is computed by f
+// whenever the metrics are rendered.
+func (r *Registry) GaugeFunc(name, help string, f func() float64) {
+	r.register(name, &gaugeFunc{name: name, help: help, f: f})
+}
+
+// Histogram registers and returns a histogram with the given upper bucket
+// bounds, which must be sorted in increasing order, and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{vec: newVec[histogramValue](name, help, labels), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// WriteTo writes all metrics to w in the Prometheus text exposition format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	collectors := make([]collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, c := range collectors {
+		c.write(bw)
+	}
+
+	err := bw.Flush()
+
+	return cw.n, err
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("content-type", "text/plain; version=0.0.4; charset=utf-8")
+
+	r.WriteTo(w)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// vec holds one value of type T per distinct combination of label values.
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+}
+
+func newVec[T any](name, help string, labels []string) *vec[T] {
+	return &vec[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*T),
+		keys:   make(map[string][]string),
+	}
+}
+
+// get returns the value for the given label values, calling init to create it
+// if it does not exist. It must be called with v.mu held.
+func (v *vec[T]) get(labelValues []string, init func() *T) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", v.name, len(v.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	t, ok := v.values[key]
+	if !ok {
+		t = init()
+		v.values[key] = t
+		v.keys[key] = append([]string(nil), labelValues...)
+	}
+
+	return t
+}
+
+// each calls f for each value in order of label values. It must be called
+// with v.mu held.
+func (v *vec[T]) each(f func(labelValues []string, t *T)) {
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f(v.keys[key], v.values[key])
+	}
+}
+
+func (v *vec[T]) writeHeader(w *bufio.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, typ)
+}
+
+// Counter is a monotonically increasing value.
+type Counter struct {
+	*vec[float64]
+}
+
+// Inc increments the counter for the given label values by 1.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter for the given label values by delta, which must
+// not be negative.
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic(fmt.Sprintf("metrics: %s cannot decrease", c.name))
+	}
+
+	c.mu.Lock()
+	*c.get(labelValues, newFloat) += delta
+	c.mu.Unlock()
+}
+
+func (c *Counter) write(w *bufio.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.writeHeader(w, "counter")
+
+	c.each(func(labelValues []string, v *float64) {
+		writeSample(w, c.name, c.labels, labelValues, "", "", *v)
+	})
+}
+
+// Gauge is a value that can go up and down.
+type Gauge struct {
+	*vec[float64]
+}
+
+// Set sets the gauge for the given label values.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	*g.get(labelValues, newFloat) = value
+	g.mu.Unlock()
+}
+
+func (g *Gauge) write(w *bufio.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.writeHeader(w, "gauge")
+
+	g.each(func(labelValues []string, v *float64) {
+		writeSample(w, g.name, g.labels, labelValues, "", "", *v)
+	})
+}
+
+type gaugeFunc struct {
+	name string
+	help string
+	f    func() float64
+}
+
+func (g *gaugeFunc) write(w *bufio.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", g.name, escapeHelp(g.help))
+	fmt.Fprintf(w, "# TYPE %s gauge\n", g.name)
+	writeSample(w, g.name, nil, nil, "", "", g.f())
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	*vec[histogramValue]
+	buckets []float64
+}
+
+type histogramValue struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+// Observe adds an observation for the given label values.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	hv := h.get(labelValues, func() *histogramValue {
+		return &histogramValue{counts: make([]uint64, len(h.buckets))}
+	})
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		hv.counts[i]++
+	}
+	hv.count++
+	hv.sum += value
+}
+
+func (h *Histogram) write(w *bufio.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.writeHeader(w, "histogram")
+
+	h.each(func(labelValues []string, hv *histogramValue) {
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += hv.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, labelValues, "le", formatFloat(upper), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, labelValues, "le", "+Inf", float64(hv.count))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, "", "", hv.sum)
+		writeSample(w, h.name+"_count", h.labels, labelValues, "", "", float64(hv.count))
+	})
+}
+
+// writeSample writes a single sample line, with an optional extra label.
+func writeSample(w *bufio.Writer, name string, labels, labelValues []string, extraLabel, extraValue string, value float64) {
+	w.WriteString(name)
+
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, label := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, label, escapeLabelValue(labelValues[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	w.WriteByte('\n')
+}
+
+func newFloat() *float64 {
+	return new(float64)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
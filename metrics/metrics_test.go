@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteTo(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.Counter("foo_requests_total", "Total number of foo requests.", "route", "code")
+	requests.Inc("/foo", "200")
+	requests.Inc("/foo", "200")
+	requests.Inc("/bar", "500")
+
+	temperature := r.Gauge("foo_temperature", "Current foo temperature.")
+	temperature.Set(21.5)
+
+	r.GaugeFunc("foo_age_seconds", "Age of foo.", func() float64 { return 42 })
+
+	duration := r.Histogram("foo_duration_seconds", "Duration of foo.", []float64{0.1, 1}, "route")
+	duration.Observe(0.05, "/foo")
+	duration.Observe(0.1, "/foo")
+	duration.Observe(0.5, "/foo")
+	duration.Observe(2, "/foo")
+
+	var buf strings.Builder
+
+	if _, err := r.WriteTo(&buf); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := `# HELP foo_requests_total Total number of foo requests.
+# TYPE foo_requests_total counter
+foo_requests_total{route="/bar",code="500"} 1
+foo_requests_total{route="/foo",code="200"} 2
+# HELP foo_temperature Current foo temperature.
+# TYPE foo_temperature gauge
+foo_temperature 21.5
+# HELP foo_age_seconds Age of foo.
+# TYPE foo_age_seconds gauge
+foo_age_seconds 42
+# HELP foo_duration_seconds Duration of foo.
+# TYPE foo_duration_seconds histogram
+foo_duration_seconds_bucket{route="/foo",le="0.1"} 2
+foo_duration_seconds_bucket{route="/foo",le="1"} 3
+foo_duration_seconds_bucket{route="/foo",le="+Inf"} 4
+foo_duration_seconds_sum{route="/foo"} 2.65
+foo_duration_seconds_count{route="/foo"} 4
+`
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistry_Escaping(t *testing.T) {
+	r := NewRegistry()
+
+	r.Counter("foo_total", "Foo with \\ and\nnewline.", "label").Inc("a \"quoted\"\nvalue\\")
+
+	var buf strings.Builder
+
+	r.WriteTo(&buf)
+
+	want := `# HELP foo_total Foo with \\ and\nnewline.
+# TYPE foo_total counter
+foo_total{label="a \"quoted\"\nvalue\\"} 1
+`
+
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	r := NewRegistry()
+
+	r.Counter("foo_total", "Foo.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate name did not panic")
+		}
+	}()
+
+	r.Gauge("foo_total", "Foo.")
+}
+
+func TestRegistry_ServeHTTP(t *testing.T) {
+	r := NewRegistry()
+
+	r.Counter("foo_total", "Foo.").Inc()
+
+	w := httptest.NewRecorder()
+
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got, want := w.Code, http.StatusOK; got != want {
+		t.Errorf("got HTTP %d, want %d", got, want)
+	}
+
+	if got, want := w.Header().Get("content-type"), "text/plain; version=0.0.4; charset=utf-8"; got != want {
+		t.Errorf("got content-type %q, want %q", got, want)
+	}
+
+	if got, want := w.Body.String(), "foo_total 1\n"; !strings.HasSuffix(got, want) {
+		t.Errorf("got body:\n%s\nwant suffix:\n%s", got, want)
+	}
+}
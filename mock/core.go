@@ -1,16 +1,60 @@
 package mock
 
-import "github.com/jsageryd/starwars-coding-test/starwars"
+import (
+	"context"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
 
 type Core struct {
-	TopFatCharactersFunc func() ([]starwars.Character, error)
-	TopOldCharactersFunc func() ([]starwars.Character, error)
+	CharactersFunc       func(ctx context.Context) ([]starwars.Character, error)
+	CharacterFunc        func(ctx context.Context, name string) (starwars.Character, error)
+	EntitiesFunc         func(ctx context.Context, kind string) ([]starwars.Entity, error)
+	SearchFunc           func(ctx context.Context, query string) ([]starwars.Character, error)
+	TopFatCharactersFunc func(ctx context.Context) ([]starwars.Character, error)
+	TopOldCharactersFunc func(ctx context.Context) ([]starwars.Character, error)
+	CacheStatusFunc      func() starwars.CacheStatus
+	PingFunc             func(ctx context.Context) error
+	PurgeCacheFunc       func()
+	RefreshCacheFunc     func(ctx context.Context) error
+}
+
+func (c *Core) Characters(ctx context.Context) ([]starwars.Character, error) {
+	return c.CharactersFunc(ctx)
+}
+
+func (c *Core) Character(ctx context.Context, name string) (starwars.Character, error) {
+	return c.CharacterFunc(ctx, name)
+}
+
+func (c *Core) Entities(ctx context.Context, kind string) ([]starwars.Entity, error) {
+	return c.EntitiesFunc(ctx, kind)
+}
+
+func (c *Core) Search(ctx context.Context, query string) ([]starwars.Character, error) {
+	return c.SearchFunc(ctx, query)
+}
+
+func (c *Core) TopFatCharacters(ctx context.Context) ([]starwars.Character, error) {
+	return c.TopFatCharactersFunc(ctx)
+}
+
+func (c *Core) TopOldCharacters(ctx context.Context) ([]starwars.Character, error) {
+	return c.TopOldCharactersFunc(ctx)
+}
+
+func (c *Core) CacheStatus() starwars.CacheStatus {
+	return c.CacheStatusFunc()
+}
+
+func (c *Core) Ping(ctx context.Context) error {
+	return c.PingFunc(ctx)
 }
 
-func (c *Core) TopFatCharacters() ([]starwars.Character, error) {
-	return c.TopFatCharactersFunc()
+func (c *Core) PurgeCache() {
+	c.PurgeCacheFunc()
 }
 
-func (c *Core) TopOldCharacters() ([]starwars.Character, error) {
-	return c.TopOldCharactersFunc()
+func (c *Core) RefreshCache(ctx context.Context) error {
+	return c.RefreshCacheFunc(ctx)
 }
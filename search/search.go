@@ -0,0 +1,321 @@
+// Package search implements full-text search of characters by name and
+// appearance, insensitive to case and diacritics and tolerant of typos.
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// Result is a character matching a query.
+type Result struct {
+	Character starwars.Character
+	Score     float64 // relevance in (0, 1], 1 if every word matches exactly
+}
+
+// Index is an index of characters. It is immutable and safe for concurrent
+// use.
+type Index struct {
+	characters []starwars.Character
+	nameWords  []int                // number of words in the name of each character
+	postings   map[string][]posting // characters by term
+}
+
+// posting is an occurrence of a term in the fields of a character.
+type posting struct {
+	character int
+	weight    float64 // weight of the field it occurs in
+}
+
+// field weights, so that matching names rank above matching appearances.
+const (
+	nameWeight       = 1
+	appearanceWeight = 0.5
+)
+
+// Limits of queries, beyond which they are truncated, so that searching is
+// cheap however long the query.
+const (
+	maxQueryRunes = 100
+	maxQueryWords = 8
+)
+
+// NewIndex returns an index of the given characters.
+func NewIndex(cs []starwars.Character) *Index {
+	idx := &Index{
+		characters: make([]starwars.Character, len(cs)),
+		nameWords:  make([]int, len(cs)),
+		postings:   make(map[string][]posting),
+	}
+
+	copy(idx.characters, cs)
+
+	for i, c := range cs {
+		weights := make(map[string]float64)
+
+		add := func(term string, weight float64) {
+			weights[term] = max(weights[term], weight)
+		}
+
+		words := tokenize(c.Name)
+		for j, word := range words {
+			add(word, nameWeight)
+
+			// Adjacent words are also indexed together, so that e.g. "r2d2"
+			// matches R2-D2.
+			if j > 0 {
+				add(words[j-1]+word, nameWeight)
+			}
+		}
+
+		idx.nameWords[i] = len(words)
+
+		for _, text := range []string{c.Gender, c.HairColor, c.SkinColor, c.EyeColor} {
+			for _, word := range tokenize(text) {
+				add(word, appearanceWeight)
+			}
+		}
+
+		for term, weight := range weights {
+			idx.postings[term] = append(idx.postings[term], posting{i, weight})
+		}
+	}
+
+	return idx
+}
+
+// Search returns at most limit characters matching every word of query,
+// ordered by relevance, or all that match if limit is 0. Words match words
+// they equal, start, are a typo away from, or share a stem of at least four
+// letters with, as nicknames do. Only the first 100 runes and 8 words of
+// query are searched for.
+func (idx *Index) Search(query string, limit int) []Result {
+	if q := []rune(query); len(q) > maxQueryRunes {
+		query = string(q[:maxQueryRunes])
+	}
+
+	words := tokenize(query)
+	if len(words) == 0 {
+		return nil
+	}
+
+	if len(words) > maxQueryWords {
+		words = words[:maxQueryWords]
+	}
+
+	scores := make([]float64, len(idx.characters))
+	matches := make([]int, len(idx.characters))
+
+	for _, word := range words {
+		best := make(map[int]float64)
+
+		for term, postings := range idx.postings {
+			m := match(word, term)
+			if m == 0 {
+				continue
+			}
+
+			for _, p := range postings {
+				best[p.character] = max(best[p.character], m*p.weight)
+			}
+		}
+
+		for i, score := range best {
+			scores[i] += score
+			matches[i]++
+		}
+	}
+
+	type candidate struct {
+		Result
+		nameWords int
+	}
+
+	var candidates []candidate
+
+	for i, c := range idx.characters {
+		if matches[i] == len(words) {
+			candidates = append(candidates, candidate{
+				Result:    Result{Character: c, Score: scores[i] / float64(len(words))},
+				nameWords: idx.nameWords[i],
+			})
+		}
+	}
+
+	// Among equally relevant characters, those with shorter names match more
+	// of their names.
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+
+		if a.nameWords != b.nameWords {
+			return a.nameWords < b.nameWords
+		}
+
+		return a.Character.Name < b.Character.Name
+	})
+
+	results := make([]Result, len(candidates))
+	for i, c := range candidates {
+		results[i] = c.Result
+	}
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return results
+}
+
+// match returns how well the query word matches the indexed term, from 0 for
+// not at all to 1 for exactly.
+func match(word, term string) float64 {
+	if word == term {
+		return 1
+	}
+
+	w, t := []rune(word), []rune(term)
+
+	if len(w) >= 2 && strings.HasPrefix(term, word) {
+		return 0.8
+	}
+
+	tolerance := typoTolerance(len(w))
+
+	// The distance is at least the difference in length, so there is no need
+	// to compute it for words of very different lengths.
+	if max(len(w), len(t))-min(len(w), len(t)) <= tolerance {
+		if d := distance(w, t); d <= tolerance {
+			return 0.8 - 0.2*float64(d)
+		}
+	}
+
+	if len(t) > len(w) {
+		if d := distance(w, t[:len(w)]); d <= tolerance {
+			return 0.6 - 0.2*float64(d)
+		}
+	}
+
+	if stem := commonPrefix(w, t); stem >= 4 && 2*stem >= len(w) {
+		return 0.3
+	}
+
+	return 0
+}
+
+// typoTolerance returns the number of typos tolerated in a word of n letters.
+func typoTolerance(n int) int {
+	switch {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// distance returns the number of insertions, deletions, substitutions and
+// transpositions of adjacent letters needed to turn a into b.
+func distance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(a)][len(b)]
+}
+
+func commonPrefix(a, b []rune) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// tokenize splits s into words of letters and digits, in lower case and
+// without diacritics.
+func tokenize(s string) []string {
+	return strings.FieldsFunc(normalize(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// normalize returns s in lower case, with Latin letters with diacritics
+// replaced by their base letters.
+func normalize(s string) string {
+	var b strings.Builder
+
+	for _, r := range strings.ToLower(s) {
+		if base, ok := folds[r]; ok {
+			b.WriteString(base)
+		} else {
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
+
+// folds maps lower-case Latin letters with diacritics, and ligatures, to their
+// base letters.
+var folds = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d",
+	'ł': "l", 'ħ': "h", 'ı': "i", 'þ': "th",
+}
+
+func init() {
+	for base, letters := range map[string]string{
+		"a": "àáâãäåāăą",
+		"c": "çćĉċč",
+		"d": "ď",
+		"e": "èéêëēĕėęě",
+		"g": "ĝğġģ",
+		"h": "ĥ",
+		"i": "ìíîïĩīĭį",
+		"j": "ĵ",
+		"k": "ķ",
+		"l": "ĺļľ",
+		"n": "ñńņň",
+		"o": "òóôõöōŏő",
+		"r": "ŕŗř",
+		"s": "śŝşš",
+		"t": "ţť",
+		"u": "ùúûüũūŭůűų",
+		"w": "ŵ",
+		"y": "ýÿŷ",
+		"z": "źżž",
+	} {
+		for _, r := range letters {
+			folds[r] = base
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+var characters = []starwars.Character{
+	{Name: "Luke Skywalker", HairColor: "blond", EyeColor: "blue", Gender: "male"},
+	{Name: "C-3PO", SkinColor: "gold", EyeColor: "yellow", Gender: "n/a"},
+	{Name: "R2-D2", SkinColor: "white, blue", EyeColor: "red", Gender: "n/a"},
+	{Name: "Darth Vader", SkinColor: "white", EyeColor: "yellow", Gender: "male"},
+	{Name: "Obi-Wan Kenobi", HairColor: "auburn, white", EyeColor: "blue-gray", Gender: "male"},
+	{Name: "Anakin Skywalker", HairColor: "blond", EyeColor: "blue", Gender: "male"},
+	{Name: "Chewbacca", HairColor: "brown", EyeColor: "blue", Gender: "male"},
+	{Name: "Padmé Amidala", HairColor: "brown", EyeColor: "brown", Gender: "female"},
+	{Name: "Wat Tambor", HairColor: "none", EyeColor: "unknown", Gender: "male"},
+}
+
+func TestIndex_Search(t *testing.T) {
+	idx := NewIndex(characters)
+
+	names := func(rs []Result) []string {
+		var names []string
+		for _, r := range rs {
+			names = append(names, r.Character.Name)
+		}
+		return names
+	}
+
+	for _, tc := range []struct {
+		query string
+		want  []string
+	}{
+		{"Luke Skywalker", []string{"Luke Skywalker"}},
+		{"luke", []string{"Luke Skywalker"}},
+		{"SKYWALKER", []string{"Anakin Skywalker", "Luke Skywalker"}},
+		{"Obi Wan", []string{"Obi-Wan Kenobi"}},
+		{"obiwan", []string{"Obi-Wan Kenobi"}},
+		{"Kenobe", []string{"Obi-Wan Kenobi"}},
+		{"Chewie", []string{"Chewbacca"}},
+		{"chew", []string{"Chewbacca"}},
+		{"Padme", []string{"Padmé Amidala"}},
+		{"PADMÉ", []string{"Padmé Amidala"}},
+		{"r2d2", []string{"R2-D2"}},
+		{"Skywakler", []string{"Anakin Skywalker", "Luke Skywalker"}},
+		{"vader", []string{"Darth Vader"}},
+		{"darth yellow", []string{"Darth Vader"}},
+		{"female", []string{"Padmé Amidala"}},
+		{"skywalker blond luke", []string{"Luke Skywalker"}},
+		{"Yoda", nil},
+		{"luke vader", nil},
+		{"", nil},
+		{" - ", nil},
+	} {
+		t.Run(tc.query, func(t *testing.T) {
+			if got, want := fmt.Sprint(names(idx.Search(tc.query, 0))), fmt.Sprint(tc.want); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		})
+	}
+
+	t.Run("Names before appearances", func(t *testing.T) {
+		idx := NewIndex([]starwars.Character{
+			{Name: "Brown Eyes", EyeColor: "blue"},
+			{Name: "Blue Eyes", EyeColor: "brown"},
+		})
+
+		if got, want := fmt.Sprint(names(idx.Search("brown", 0))), "[Brown Eyes Blue Eyes]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Exact before fuzzy", func(t *testing.T) {
+		rs := idx.Search("wat", 0)
+
+		if len(rs) == 0 {
+			t.Fatal("got no results")
+		}
+
+		if got, want := rs[0].Character.Name, "Wat Tambor"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if got, want := rs[0].Score, 1.0; got != want {
+			t.Errorf("got score %g, want %g", got, want)
+		}
+	})
+
+	t.Run("Limit", func(t *testing.T) {
+		if got, want := fmt.Sprint(names(idx.Search("skywalker", 1))), "[Anakin Skywalker]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Oversized query", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc  string
+			query string
+			want  string
+		}{
+			{"Long word", strings.Repeat("a", 1e6), "[]"},
+			{"Many words", strings.Repeat("luke ", 1e5), "[Luke Skywalker]"},
+			{"Words beyond limit", strings.Repeat("luke ", maxQueryWords) + "vader", "[Luke Skywalker]"},
+			{"Letters beyond limit", "luke" + strings.Repeat(" ", maxQueryRunes) + "vader", "[Luke Skywalker]"},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				if got, want := fmt.Sprint(names(idx.Search(tc.query, 0))), tc.want; got != want {
+					t.Errorf("got %s, want %s", got, want)
+				}
+			})
+		}
+	})
+}
+
+func TestDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"luke", "luke", 0},
+		{"luke", "luk", 1},
+		{"luke", "lukas", 2},
+		{"kenobe", "kenobi", 1},
+		{"skywakler", "skywalker", 1},
+		{"chewie", "chewbacca", 5},
+	} {
+		if got := distance([]rune(tc.a), []rune(tc.b)); got != tc.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want []string
+	}{
+		{"Obi-Wan Kenobi", []string{"obi", "wan", "kenobi"}},
+		{"  Padmé   Amidala ", []string{"padme", "amidala"}},
+		{"R2-D2", []string{"r2", "d2"}},
+		{"Ça, ŒUVRE, Straße", []string{"ca", "oeuvre", "strasse"}},
+		{"", nil},
+	} {
+		if got, want := fmt.Sprint(tokenize(tc.s)), fmt.Sprint(tc.want); got != want {
+			t.Errorf("tokenize(%q) = %s, want %s", tc.s, got, want)
+		}
+	}
+}
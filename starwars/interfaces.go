@@ -1,6 +1,16 @@
 package starwars
 
+import "context"
+
 type Core interface {
-	TopFatCharacters() ([]Character, error)
-	TopOldCharacters() ([]Character, error)
+	Characters(ctx context.Context) ([]Character, error)
+	Character(ctx context.Context, name string) (Character, error)
+	Entities(ctx context.Context, kind string) ([]Entity, error)
+	Search(ctx context.Context, query string) ([]Character, error)
+	TopFatCharacters(ctx context.Context) ([]Character, error)
+	TopOldCharacters(ctx context.Context) ([]Character, error)
+	CacheStatus() CacheStatus
+	Ping(ctx context.Context) error
+	PurgeCache()
+	RefreshCache(ctx context.Context) error
 }
@@ -1,8 +1,173 @@
 package starwars
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when a requested character or entity does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrUnavailable is returned when requested data is not available from the
+// data source, e.g. entities when serving offline data.
+var ErrUnavailable = errors.New("not available")
+
 type Character struct {
-	Name      string  `json:"name"`
-	Height    float64 `json:"height,string,omitempty"` // height in cm
-	Mass      float64 `json:"mass,string,omitempty"`   // mass in kg
-	BirthYear string  `json:"birth_year,omitempty"`    // birth year, e.g. "<year>BBY" or "<year>ABY"
+	Name      string   `json:"name"`
+	Height    float64  `json:"height,string,omitempty"` // height in cm
+	Mass      float64  `json:"mass,string,omitempty"`   // mass in kg
+	BirthYear string   `json:"birth_year,omitempty"`    // birth year, e.g. "<year>BBY" or "<year>ABY"
+	HairColor string   `json:"hair_color,omitempty"`
+	SkinColor string   `json:"skin_color,omitempty"`
+	EyeColor  string   `json:"eye_color,omitempty"`
+	Gender    string   `json:"gender,omitempty"`
+	Homeworld string   `json:"homeworld,omitempty"` // URL of a planet
+	Films     []string `json:"films,omitempty"`     // URLs of films
+	Species   []string `json:"species,omitempty"`   // URLs of species
+	Starships []string `json:"starships,omitempty"` // URLs of starships
+	Vehicles  []string `json:"vehicles,omitempty"`  // URLs of vehicles
+	URL       string   `json:"url,omitempty"`
+}
+
+// UnmarshalJSON decodes a character as SWAPI serves it. Heights and masses
+// are strings, which may be "unknown" and may have thousands separators, as in
+// "1,358". Unknown heights and masses are decoded as 0.
+func (c *Character) UnmarshalJSON(b []byte) error {
+	type character Character // without this method
+
+	v := struct {
+		*character
+		Height string `json:"height"`
+		Mass   string `json:"mass"`
+	}{character: (*character)(c)}
+
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	var err error
+
+	if c.Height, err = parseMeasure(v.Height); err != nil {
+		return fmt.Errorf("invalid height: %v", err)
+	}
+
+	if c.Mass, err = parseMeasure(v.Mass); err != nil {
+		return fmt.Errorf("invalid mass: %v", err)
+	}
+
+	return nil
+}
+
+// parseMeasure parses a height or mass as SWAPI serves it, returning 0 if it
+// is unknown.
+func parseMeasure(s string) (float64, error) {
+	if s == "" || s == "unknown" {
+		return 0, nil
+	}
+
+	return strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+}
+
+// BMI returns the body mass index of the character, or ok=false if its height
+// or mass is unknown.
+func (c Character) BMI() (bmi float64, ok bool) {
+	if c.Height <= 0 || c.Mass <= 0 {
+		return 0, false
+	}
+	heightM := c.Height / 100
+	return c.Mass / (heightM * heightM), true
+}
+
+// AgeBBY returns the age of the character in years at the Battle of Yavin,
+// negative if born after it, or ok=false if its birth year is unknown.
+func (c Character) AgeBBY() (age float64, ok bool) {
+	year, err := ParseBirthYear(c.BirthYear)
+	if err != nil {
+		return 0, false
+	}
+	return -year, true
+}
+
+// ParseBirthYear parses a birth year like "19BBY" or "4ABY" into years
+// relative to the Battle of Yavin, negative before it.
+func ParseBirthYear(year string) (float64, error) {
+	if len(year) < 4 {
+		return 0, fmt.Errorf("unknown birth year format: %s", year)
+	}
+
+	numberStr := year[:len(year)-3]
+	suffix := year[len(year)-3:]
+
+	number, err := strconv.ParseFloat(numberStr, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unknown birth year format: %s", year)
+	}
+
+	switch suffix {
+	case "BBY":
+		return -number, nil
+	case "ABY":
+		return number, nil
+	default:
+		return 0, fmt.Errorf("unknown birth year format: %s", year)
+	}
+}
+
+// Kinds of entities that characters refer to.
+const (
+	Films     = "films"
+	Planets   = "planets"
+	Species   = "species"
+	Starships = "starships"
+	Vehicles  = "vehicles"
+)
+
+// Kinds lists all kinds of entities.
+var Kinds = []string{Films, Planets, Species, Starships, Vehicles}
+
+// Entity is a film, planet, species, starship or vehicle.
+type Entity struct {
+	URL        string
+	Name       string      // title for films
+	Attributes []Attribute // attributes other than the name and references, sorted by key
+}
+
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// ID returns the identifier at the end of a SWAPI URL, e.g. "1" for
+// "https://swapi.dev/api/planets/1/".
+func ID(url string) string {
+	return path.Base(strings.TrimRight(url, "/"))
+}
+
+type CacheStatus struct {
+	Generation uint64        // incremented on every cache update, 0 if never populated
+	Entries    int           // number of cached characters
+	Updated    time.Time     // time of the last cache update, zero if purged since
+	TTL        time.Duration // time to live of cached data, 0 if it never expires
+	LastError  string        // error from the last failed update, empty if it succeeded
+}
+
+// Age returns the age of the cached data at time t.
+func (s CacheStatus) Age(t time.Time) time.Duration {
+	if s.Generation == 0 || s.Updated.IsZero() {
+		return 0
+	}
+	return t.Sub(s.Updated)
+}
+
+// Fresh reports whether the cache holds data that has not expired at time t.
+func (s CacheStatus) Fresh(t time.Time) bool {
+	if s.Generation == 0 || s.Updated.IsZero() {
+		return false
+	}
+	return s.TTL == 0 || s.Age(t) < s.TTL
 }
@@ -2,33 +2,100 @@ package swapi
 
 import (
 	"sync"
+	"time"
 
+	"github.com/jsageryd/starwars-coding-test/search"
 	"github.com/jsageryd/starwars-coding-test/starwars"
 )
 
 type cache struct {
 	mu         sync.RWMutex
+	ttl        time.Duration
+	now        func() time.Time
 	characters []starwars.Character
+	index      *search.Index // index of the characters, rebuilt when they are set
+	generation uint64
+	updated    time.Time
+	lastErr    error
 }
 
-func newCache() *cache {
-	return &cache{}
+func newCache(ttl time.Duration) *cache {
+	return &cache{
+		ttl: ttl,
+		now: time.Now,
+	}
 }
 
 func (c *cache) SetCharacters(cs []starwars.Character) {
+	index := search.NewIndex(cs)
+
 	c.mu.Lock()
 	c.characters = make([]starwars.Character, len(cs))
 	copy(c.characters, cs)
+	c.index = index
+	c.generation++
+	c.updated = c.now()
+	c.lastErr = nil
+	c.mu.Unlock()
+}
+
+// SetError records an error from a failed attempt to update the cache. Cached
+// data, if any, is kept.
+func (c *cache) SetError(err error) {
+	c.mu.Lock()
+	c.lastErr = err
 	c.mu.Unlock()
 }
 
+// Purge drops the cached characters. The generation is kept, so that it keeps
+// identifying the data that is cached.
+func (c *cache) Purge() {
+	c.mu.Lock()
+	c.characters = nil
+	c.index = nil
+	c.updated = time.Time{}
+	c.mu.Unlock()
+}
+
+// GetCharacters returns the cached characters, or ok=false if the cache has
+// not been populated or its data has expired.
 func (c *cache) GetCharacters() (cs []starwars.Character, ok bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	if c.characters == nil {
+	if c.characters == nil || !c.status().Fresh(c.now()) {
 		return nil, false
 	}
 	cs = make([]starwars.Character, len(c.characters))
 	copy(cs, c.characters)
 	return cs, true
 }
+
+// GetIndex returns the search index of the cached characters, or ok=false if
+// the cache has not been populated or its data has expired.
+func (c *cache) GetIndex() (index *search.Index, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.index == nil || !c.status().Fresh(c.now()) {
+		return nil, false
+	}
+	return c.index, true
+}
+
+func (c *cache) Status() starwars.CacheStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.status()
+}
+
+func (c *cache) status() starwars.CacheStatus {
+	status := starwars.CacheStatus{
+		Generation: c.generation,
+		Entries:    len(c.characters),
+		Updated:    c.updated,
+		TTL:        c.ttl,
+	}
+	if c.lastErr != nil {
+		status.LastError = c.lastErr.Error()
+	}
+	return status
+}
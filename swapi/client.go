@@ -1,52 +1,206 @@
 package swapi
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/jsageryd/starwars-coding-test/metrics"
+	"github.com/jsageryd/starwars-coding-test/search"
 	"github.com/jsageryd/starwars-coding-test/starwars"
+	"github.com/jsageryd/starwars-coding-test/tracing"
 )
 
 type Client struct {
-	baseURL string
-	cache   *cache
+	baseURL    string
+	cacheTTL   time.Duration
+	retries    int
+	offline    bool
+	data       []starwars.Character
+	httpClient *http.Client
+	registry   *metrics.Registry
+	cache      *cache
+	resources  *resourceCache
+	metrics    *clientMetrics
 }
 
-func NewClient(baseURL string) *Client {
-	return &Client{
-		cache:   newCache(),
-		baseURL: strings.TrimRight(baseURL, "/"),
+type Option func(*Client)
+
+// WithCacheTTL sets how long fetched data is cached before it is fetched
+// again. The default of 0 caches data indefinitely.
+func WithCacheTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.cacheTTL = ttl
+	}
+}
+
+// WithRetries sets how many times a failed SWAPI request is retried before
+// giving up. Requests are retried on network errors and on HTTP 429 and 5xx
+// responses, with exponential backoff. The default is not to retry.
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// WithTimeout sets the timeout of a single SWAPI request. The default is no
+// timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient = &http.Client{Timeout: timeout}
+	}
+}
+
+// WithOfflineData makes the client serve the given characters instead of
+// querying SWAPI. The data never expires and refreshing it does nothing.
+func WithOfflineData(cs []starwars.Character) Option {
+	return func(c *Client) {
+		c.offline = true
+		c.data = cs
+	}
+}
+
+// WithMetrics registers metrics for SWAPI requests and the cache in reg.
+func WithMetrics(reg *metrics.Registry) Option {
+	return func(c *Client) {
+		c.registry = reg
 	}
 }
 
-func (c *Client) People() ([]starwars.Character, error) {
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		registry:   metrics.NewRegistry(),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.offline {
+		c.cacheTTL = 0
+	}
+
+	c.cache = newCache(c.cacheTTL)
+	c.resources = newResourceCache(c.cacheTTL)
+	c.metrics = newClientMetrics(c.registry, c)
+
+	if c.offline {
+		c.cache.SetCharacters(c.data)
+	}
+
+	return c
+}
+
+// CacheStatus returns the current status of the character cache.
+func (c *Client) CacheStatus() starwars.CacheStatus {
+	return c.cache.Status()
+}
+
+func (c *Client) People(ctx context.Context) ([]starwars.Character, error) {
 	if cs, ok := c.cache.GetCharacters(); ok {
+		c.metrics.cacheHits.Inc()
 		return cs, nil
 	}
 
-	var characters []starwars.Character
+	c.metrics.cacheMisses.Inc()
 
-	nextURL := c.baseURL + "/people/"
+	return c.fetchAndCachePeople(ctx)
+}
 
-	for nextURL != "" {
-		resp, err := http.Get(nextURL)
-		if err != nil {
-			return nil, fmt.Errorf("error querying SWAPI: %v", err)
-		}
+// CachedIndex returns a search index of the cached characters, which is
+// rebuilt whenever they are updated, or ok=false if they are not cached. It
+// never queries SWAPI.
+func (c *Client) CachedIndex() (index *search.Index, ok bool) {
+	if index, ok = c.cache.GetIndex(); ok {
+		c.metrics.cacheHits.Inc()
+	} else {
+		c.metrics.cacheMisses.Inc()
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("SWAPI returned HTTP %d", resp.StatusCode)
-		}
+	return index, ok
+}
+
+// Refresh fetches all characters from SWAPI and updates the cache, regardless
+// of whether the cached data has expired. If fetching fails, previously cached
+// data is kept.
+func (c *Client) Refresh(ctx context.Context) error {
+	if c.offline {
+		return nil
+	}
+
+	_, err := c.fetchAndCachePeople(ctx)
+	return err
+}
+
+// Purge drops the cached characters and entities, so that they are fetched
+// from SWAPI again when next needed. It does nothing when serving offline data.
+func (c *Client) Purge() {
+	if c.offline {
+		return
+	}
+
+	c.cache.Purge()
+	c.resources.Purge()
+}
+
+func (c *Client) fetchAndCachePeople(ctx context.Context) ([]starwars.Character, error) {
+	characters, err := c.fetchPeople(ctx)
+	if err != nil {
+		c.cache.SetError(err)
+		return nil, err
+	}
+
+	c.cache.SetCharacters(characters)
+
+	return characters, nil
+}
+
+// Ping checks that SWAPI is reachable and responding.
+func (c *Client) Ping(ctx context.Context) error {
+	if c.offline {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/", nil)
+	if err != nil {
+		return fmt.Errorf("error creating SWAPI request: %v", err)
+	}
+
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error querying SWAPI: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("SWAPI returned HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *Client) fetchPeople(ctx context.Context) ([]starwars.Character, error) {
+	var characters []starwars.Character
+
+	nextURL := c.baseURL + "/" + people + "/"
 
+	for nextURL != "" {
 		var respBody struct {
 			Next    string               `json:"next"`
 			Results []starwars.Character `json:"results"`
 		}
 
-		if json.NewDecoder(resp.Body).Decode(&respBody); err != nil {
-			return nil, fmt.Errorf("error reading SWAPI response: %v", err)
+		if err := c.getPage(ctx, people, nextURL, &respBody); err != nil {
+			return nil, err
 		}
 
 		characters = append(characters, respBody.Results...)
@@ -54,7 +208,92 @@ func (c *Client) People() ([]starwars.Character, error) {
 		nextURL = respBody.Next
 	}
 
-	c.cache.SetCharacters(characters)
-
 	return characters, nil
 }
+
+// getPage fetches a page of the given resource from url and decodes it into v,
+// retrying temporary failures.
+func (c *Client) getPage(ctx context.Context, resource, url string, v any) error {
+	ctx, span := tracing.Start(ctx, "GET "+resource, tracing.KindClient,
+		tracing.Attr("http.method", http.MethodGet),
+		tracing.Attr("http.url", url),
+		tracing.Attr("swapi.resource", resource),
+	)
+	defer span.End()
+
+	backoff := 100 * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		start := time.Now()
+
+		err := c.getJSON(ctx, url, v)
+
+		duration := time.Since(start)
+
+		c.metrics.pageDuration.Observe(duration.Seconds(), resource)
+
+		if err == nil {
+			span.SetAttributes(tracing.Attr("attempts", attempt+1))
+			slog.InfoContext(ctx, "Fetched SWAPI page", "url", url, "duration", duration, "attempt", attempt+1)
+			return nil
+		}
+
+		c.metrics.pageErrors.Inc(resource)
+
+		slog.WarnContext(ctx, "Error fetching SWAPI page", "url", url, "duration", duration, "attempt", attempt+1, "error", err)
+
+		var tempErr temporaryError
+		if attempt >= c.retries || !errors.As(err, &tempErr) {
+			span.SetAttributes(tracing.Attr("attempts", attempt+1))
+			span.RecordError(err)
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			span.RecordError(err)
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// temporaryError is an error from a SWAPI request that may succeed if retried.
+type temporaryError struct {
+	err error
+}
+
+func (e temporaryError) Error() string {
+	return e.err.Error()
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("error creating SWAPI request: %v", err)
+	}
+
+	tracing.Inject(ctx, req.Header)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return temporaryError{fmt.Errorf("error querying SWAPI: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("SWAPI returned HTTP %d", resp.StatusCode)
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return temporaryError{err}
+		}
+		return err
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("error reading SWAPI response: %v", err)
+	}
+
+	return nil
+}
@@ -1,12 +1,19 @@
 package swapi
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/jsageryd/starwars-coding-test/metrics"
 	"github.com/jsageryd/starwars-coding-test/starwars"
+	"github.com/jsageryd/starwars-coding-test/tracing"
 )
 
 func TestClient_People(t *testing.T) {
@@ -44,7 +51,7 @@ func TestClient_People(t *testing.T) {
 
 		c := NewClient(ts.URL)
 
-		gotCharacters, err := c.People()
+		gotCharacters, err := c.People(context.Background())
 		if err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
@@ -83,7 +90,7 @@ func TestClient_People(t *testing.T) {
 		var err error
 
 		for n := 0; n < 2; n++ {
-			gotCharacters, err = c.People()
+			gotCharacters, err = c.People(context.Background())
 			if err != nil {
 				t.Fatalf("unexpected error: %v", err)
 			}
@@ -102,6 +109,145 @@ func TestClient_People(t *testing.T) {
 		}
 	})
 
+	t.Run("Cache expiry", func(t *testing.T) {
+		var gotReqCount int
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotReqCount++
+
+				w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+			},
+		))
+
+		c := NewClient(ts.URL, WithCacheTTL(time.Minute))
+
+		now := time.Date(2023, 6, 13, 12, 0, 0, 0, time.UTC)
+		c.cache.now = func() time.Time { return now }
+
+		for n := 0; n < 2; n++ {
+			if _, err := c.People(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if got, want := gotReqCount, 1; got != want {
+			t.Errorf("sent %d requests before expiry, want %d", got, want)
+		}
+
+		now = now.Add(time.Minute)
+
+		if _, err := c.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := gotReqCount, 2; got != want {
+			t.Errorf("sent %d requests after expiry, want %d", got, want)
+		}
+
+		wantStatus := starwars.CacheStatus{Generation: 2, Entries: 1, Updated: now, TTL: time.Minute}
+
+		if got, want := c.CacheStatus(), wantStatus; got != want {
+			t.Errorf("got cache status %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Non-OK response from API", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		_, err := c.People(context.Background())
+
+		if err == nil {
+			t.Fatal("error is nil")
+		}
+
+		if got, want := err.Error(), "SWAPI returned HTTP 418"; got != want {
+			t.Errorf("error is %q, want %q", got, want)
+		}
+
+		if got, want := c.CacheStatus().LastError, "SWAPI returned HTTP 418"; got != want {
+			t.Errorf("cache last error is %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Malformed response from API", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc       string
+			body       string
+			wantErrStr string
+		}{
+			{
+				desc:       "Truncated",
+				body:       `{"results": [{"name":"C-3PO","height":"167"`,
+				wantErrStr: "error reading SWAPI response: unexpected EOF",
+			},
+			{
+				desc:       "Invalid mass",
+				body:       `{"results": [{"name":"C-3PO","height":"167","mass":"heavy"}]}`,
+				wantErrStr: `error reading SWAPI response: invalid mass: strconv.ParseFloat: parsing "heavy": invalid syntax`,
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				var gotReqCount int
+
+				ts := httptest.NewServer(http.HandlerFunc(
+					func(w http.ResponseWriter, r *http.Request) {
+						gotReqCount++
+
+						w.Write([]byte(tc.body))
+					},
+				))
+
+				c := NewClient(ts.URL)
+
+				for i := 0; i < 2; i++ {
+					_, err := c.People(context.Background())
+
+					if err == nil {
+						t.Fatal("error is nil")
+					}
+
+					if got, want := err.Error(), tc.wantErrStr; got != want {
+						t.Errorf("error is %q, want %q", got, want)
+					}
+				}
+
+				// Nothing is cached, so every call queries SWAPI
+				if got, want := gotReqCount, 2; got != want {
+					t.Errorf("sent %d requests, want %d", got, want)
+				}
+
+				if got, want := c.CacheStatus().Generation, uint64(0); got != want {
+					t.Errorf("cache generation is %d, want %d", got, want)
+				}
+			})
+		}
+	})
+}
+
+func TestClient_Ping(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if got, want := r.URL.Path, "/"; got != want {
+					t.Errorf("got path %q, want %q", got, want)
+				}
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		if err := c.Ping(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
 	t.Run("Non-OK response from API", func(t *testing.T) {
 		ts := httptest.NewServer(http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
@@ -111,7 +257,7 @@ func TestClient_People(t *testing.T) {
 
 		c := NewClient(ts.URL)
 
-		_, err := c.People()
+		err := c.Ping(context.Background())
 
 		if err == nil {
 			t.Fatal("error is nil")
@@ -122,3 +268,816 @@ func TestClient_People(t *testing.T) {
 		}
 	})
 }
+
+func TestClient_Metrics(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("page") == "2" {
+				w.WriteHeader(http.StatusTeapot)
+				return
+			}
+			w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+		},
+	))
+
+	reg := metrics.NewRegistry()
+
+	c := NewClient(ts.URL, WithMetrics(reg))
+
+	for n := 0; n < 2; n++ {
+		if _, err := c.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	var pageResp struct{}
+
+	c.getPage(context.Background(), "people", ts.URL+"/people/?page=2", &pageResp)
+
+	var buf strings.Builder
+
+	reg.WriteTo(&buf)
+
+	for _, wantLine := range []string{
+		`swapi_cache_entries 1`,
+		`swapi_cache_hits_total 1`,
+		`swapi_cache_misses_total 1`,
+		`swapi_page_errors_total{resource="people"} 1`,
+		`swapi_page_request_duration_seconds_count{resource="people"} 2`,
+	} {
+		if !strings.Contains(buf.String(), wantLine+"\n") {
+			t.Errorf("metrics do not contain %q:\n%s", wantLine, buf.String())
+		}
+	}
+}
+
+func TestClient_CachedIndex(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var gotReqCount int
+
+		name := "C-3PO"
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotReqCount++
+
+				w.Write([]byte(`{"results": [{"name":"` + name + `"}]}`))
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		search := func(query string) string {
+			t.Helper()
+
+			index, ok := c.CachedIndex()
+			if !ok {
+				t.Fatal("index is not cached")
+			}
+
+			var names []string
+			for _, r := range index.Search(query, 0) {
+				names = append(names, r.Character.Name)
+			}
+
+			return fmt.Sprint(names)
+		}
+
+		if _, ok := c.CachedIndex(); ok {
+			t.Error("index is cached before characters are")
+		}
+
+		if got, want := gotReqCount, 0; got != want {
+			t.Errorf("sent %d requests, want %d", got, want)
+		}
+
+		if _, err := c.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := search("c3po"), "[C-3PO]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if got, want := gotReqCount, 1; got != want {
+			t.Errorf("sent %d requests, want %d", got, want)
+		}
+
+		// The index is rebuilt when the cache is refreshed
+		name = "R2-D2"
+
+		if err := c.Refresh(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := search("r2d2"), "[R2-D2]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if got, want := search("c3po"), "[]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Offline data", func(t *testing.T) {
+		c := NewClient("http://localhost:0", WithOfflineData([]starwars.Character{{Name: "Yoda"}}))
+
+		index, ok := c.CachedIndex()
+		if !ok {
+			t.Fatal("index is not cached")
+		}
+
+		if got, want := len(index.Search("yoda", 0)), 1; got != want {
+			t.Errorf("got %d results, want %d", got, want)
+		}
+	})
+}
+
+func TestClient_Refresh(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var gotReqCount int
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotReqCount++
+
+				w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		for n := 0; n < 2; n++ {
+			if err := c.Refresh(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		if got, want := gotReqCount, 2; got != want {
+			t.Errorf("sent %d requests, want %d", got, want)
+		}
+
+		if got, want := c.CacheStatus().Generation, uint64(2); got != want {
+			t.Errorf("cache generation is %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Error keeps cached data", func(t *testing.T) {
+		fail := false
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				if fail {
+					w.WriteHeader(http.StatusTeapot)
+					return
+				}
+				w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		if err := c.Refresh(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		fail = true
+
+		if err := c.Refresh(context.Background()); err == nil {
+			t.Fatal("error is nil")
+		}
+
+		gotCharacters, err := c.People(context.Background())
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		wantCharacters := []starwars.Character{{Name: "C-3PO"}}
+
+		if fmt.Sprint(gotCharacters) != fmt.Sprint(wantCharacters) {
+			t.Errorf("got %v, want %v", gotCharacters, wantCharacters)
+		}
+	})
+
+	t.Run("Cancelled context", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		if err := c.Refresh(ctx); err == nil {
+			t.Fatal("error is nil")
+		}
+
+		if got, want := c.CacheStatus().Generation, uint64(0); got != want {
+			t.Errorf("cache generation is %d, want %d", got, want)
+		}
+	})
+}
+
+func TestClient_Purge(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var gotReqCount int
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotReqCount++
+
+				w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+			},
+		))
+
+		c := NewClient(ts.URL)
+
+		if _, err := c.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		c.Purge()
+
+		status := c.CacheStatus()
+
+		if got, want := status.Entries, 0; got != want {
+			t.Errorf("got %d entries, want %d", got, want)
+		}
+
+		if got, want := status.Generation, uint64(1); got != want {
+			t.Errorf("cache generation is %d, want %d", got, want)
+		}
+
+		if status.Fresh(time.Now()) {
+			t.Error("purged cache is fresh")
+		}
+
+		if _, err := c.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := gotReqCount, 2; got != want {
+			t.Errorf("sent %d requests, want %d", got, want)
+		}
+
+		if got, want := c.CacheStatus().Generation, uint64(2); got != want {
+			t.Errorf("cache generation is %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		c := NewClient("http://localhost:0", WithOfflineData([]starwars.Character{{Name: "C-3PO"}}))
+
+		c.Purge()
+
+		if got, want := c.CacheStatus().Entries, 1; got != want {
+			t.Errorf("got %d entries, want %d", got, want)
+		}
+	})
+}
+
+func TestClient_Resources(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		var gotReqCount int
+		var nextURL string
+
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				gotReqCount++
+
+				switch path := r.URL.Path; path {
+				case "/planets/":
+					w.Write([]byte(`
+{
+  "next": "` + nextURL + `",
+  "results": [
+    {"name":"Tatooine","climate":"arid","diameter":"10465","residents":["https://swapi.dev/api/people/1/"],"created":"2014-12-09T13:50:49.641000Z","url":"https://swapi.dev/api/planets/1/"}
+  ]
+}
+`))
+				case "/foo-next-page":
+					w.Write([]byte(`
+{
+  "results": [
+    {"name":"Alderaan","climate":"temperate","url":"https://swapi.dev/api/planets/2/"}
+  ]
+}
+`))
+				default:
+					t.Fatalf("unexpected path: %s", path)
+				}
+			},
+		))
+
+		nextURL = ts.URL + "/foo-next-page"
+
+		c := NewClient(ts.URL)
+
+		for i := 0; i < 2; i++ {
+			gotEntities, err := c.Resources(context.Background(), starwars.Planets)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			wantEntities := []starwars.Entity{
+				{URL: "https://swapi.dev/api/planets/1/", Name: "Tatooine", Attributes: []starwars.Attribute{{Key: "climate", Value: "arid"}, {Key: "diameter", Value: "10465"}}},
+				{URL: "https://swapi.dev/api/planets/2/", Name: "Alderaan", Attributes: []starwars.Attribute{{Key: "climate", Value: "temperate"}}},
+			}
+
+			if fmt.Sprint(gotEntities) != fmt.Sprint(wantEntities) {
+				t.Errorf("got %v, want %v", gotEntities, wantEntities)
+			}
+		}
+
+		if got, want := gotReqCount, 2; got != want {
+			t.Errorf("sent %d requests, want %d", got, want)
+		}
+
+		c.Purge()
+
+		if _, err := c.Resources(context.Background(), starwars.Planets); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := gotReqCount, 4; got != want {
+			t.Errorf("sent %d requests after purging, want %d", got, want)
+		}
+	})
+
+	t.Run("Film titles", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(`{"results": [{"title":"A New Hope","episode_id":4,"url":"https://swapi.dev/api/films/1/"}]}`))
+			},
+		))
+
+		gotEntities, err := NewClient(ts.URL).Resources(context.Background(), starwars.Films)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := fmt.Sprint(gotEntities), "[{https://swapi.dev/api/films/1/ A New Hope [{episode_id 4}]}]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Unknown resource", func(t *testing.T) {
+		if _, err := NewClient("http://localhost:0").Resources(context.Background(), "foo"); err == nil {
+			t.Error("error is nil")
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		c := NewClient("http://localhost:0", WithOfflineData([]starwars.Character{{Name: "C-3PO"}}))
+
+		if _, err := c.Resources(context.Background(), starwars.Planets); err != ErrOffline {
+			t.Errorf("error is %v, want %v", err, ErrOffline)
+		}
+	})
+}
+
+// fakeSearchSWAPI returns a server that serves and searches the given
+// resources like SWAPI, counting requests by path and whether they search.
+func fakeSearchSWAPI(t *testing.T, resources map[string][]map[string]any, fields map[string][]string) (*httptest.Server, map[string]int) {
+	requests := make(map[string]int)
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			resource := strings.Trim(r.URL.Path, "/")
+
+			results, ok := resources[resource]
+			if !ok {
+				t.Fatalf("unexpected path: %s", r.URL.Path)
+			}
+
+			query, searching := r.URL.Query()["search"]
+			if !searching {
+				requests[resource]++
+				json.NewEncoder(w).Encode(map[string]any{"results": results})
+				return
+			}
+
+			requests[resource+"?search"]++
+
+			matches := []map[string]any{}
+
+		results:
+			for _, result := range results {
+				for _, term := range strings.Fields(strings.ReplaceAll(query[0], ",", " ")) {
+					var found bool
+					for _, field := range fields[resource] {
+						if s, _ := result[field].(string); strings.Contains(strings.ToLower(s), strings.ToLower(term)) {
+							found = true
+						}
+					}
+					if !found {
+						continue results
+					}
+				}
+				matches = append(matches, result)
+			}
+
+			// Serve one result per page, to exercise paging
+			var next string
+			if page := r.URL.Query().Get("page"); page == "" && len(matches) > 1 {
+				next = "http://" + r.Host + r.URL.Path + "?search=" + url.QueryEscape(query[0]) + "&page=2"
+				matches = matches[:1]
+			} else if page == "2" {
+				matches = matches[1:]
+			}
+
+			json.NewEncoder(w).Encode(map[string]any{"next": next, "results": matches})
+		},
+	))
+
+	t.Cleanup(ts.Close)
+
+	return ts, requests
+}
+
+func TestClient_Search(t *testing.T) {
+	resources := map[string][]map[string]any{
+		"people": {
+			{"name": "Luke Skywalker", "height": "172", "mass": "77", "birth_year": "19BBY", "homeworld": "https://swapi.dev/api/planets/1/", "films": []string{"https://swapi.dev/api/films/1/"}, "created": "2014-12-09T13:50:51.644000Z", "url": "https://swapi.dev/api/people/1/"},
+			{"name": "C-3PO", "height": "167", "mass": "75", "birth_year": "112BBY", "url": "https://swapi.dev/api/people/2/"},
+			{"name": "Anakin Skywalker", "height": "188", "mass": "84", "birth_year": "41.9BBY", "url": "https://swapi.dev/api/people/11/"},
+			{"name": "Padmé Amidala", "height": "185", "mass": "45", "birth_year": "46BBY", "url": "https://swapi.dev/api/people/35/"},
+			{"name": "Jabba Desilijic Tiure", "height": "175", "mass": "1,358", "birth_year": "600BBY", "url": "https://swapi.dev/api/people/16/"},
+			{"name": "Arvel Crynyd", "height": "unknown", "mass": "unknown", "birth_year": "unknown", "url": "https://swapi.dev/api/people/28/"},
+		},
+		"starships": {
+			{"name": "Millennium Falcon", "model": "YT-1300 light freighter", "url": "https://swapi.dev/api/starships/10/"},
+			{"name": "X-wing", "model": "T-65 X-wing", "url": "https://swapi.dev/api/starships/12/"},
+			{"name": "Slave 1", "model": "Firespray-31-class patrol and attack", "url": "https://swapi.dev/api/starships/21/"},
+		},
+		"films": {
+			{"title": "A New Hope", "episode_id": 4, "url": "https://swapi.dev/api/films/1/"},
+			{"title": "The Empire Strikes Back", "episode_id": 5, "url": "https://swapi.dev/api/films/2/"},
+		},
+	}
+
+	fields := map[string][]string{
+		"people":    {"name"},
+		"starships": {"name", "model"},
+		"films":     {"title"},
+	}
+
+	names := func(es []starwars.Entity) string {
+		var names []string
+		for _, e := range es {
+			names = append(names, e.Name)
+		}
+		return fmt.Sprint(names)
+	}
+
+	t.Run("Cold cache", func(t *testing.T) {
+		ts, requests := fakeSearchSWAPI(t, resources, fields)
+
+		c := NewClient(ts.URL)
+
+		gotEntities, err := c.Search(context.Background(), "people", "skywalker")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := names(gotEntities), "[Luke Skywalker Anakin Skywalker]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if got, want := fmt.Sprint(gotEntities[0]), "{https://swapi.dev/api/people/1/ Luke Skywalker [{birth_year 19BBY} {height 172} {mass 77}]}"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if got, want := fmt.Sprint(requests), "map[people?search:2]"; got != want {
+			t.Errorf("sent requests %s, want %s", got, want)
+		}
+
+		if got, want := c.CacheStatus().Generation, uint64(0); got != want {
+			t.Errorf("cache generation is %d, want %d", got, want)
+		}
+	})
+
+	t.Run("Warm cache", func(t *testing.T) {
+		ts, requests := fakeSearchSWAPI(t, resources, fields)
+
+		c := NewClient(ts.URL)
+
+		if _, err := c.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if _, err := c.Resources(context.Background(), starwars.Starships); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		gotEntities, err := c.Search(context.Background(), "people", "SKY luke")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := names(gotEntities), "[Luke Skywalker]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		gotEntities, err = c.Search(context.Background(), starwars.Starships, "x-wing")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := names(gotEntities), "[X-wing]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if got, want := fmt.Sprint(requests), "map[people:1 starships:1]"; got != want {
+			t.Errorf("sent requests %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Unknown and formatted measures", func(t *testing.T) {
+		ts, _ := fakeSearchSWAPI(t, resources, fields)
+
+		c := NewClient(ts.URL)
+
+		for query, want := range map[string]string{
+			"jabba": "[{Jabba Desilijic Tiure 175 1358 600BBY}]",
+			"arvel": "[{Arvel Crynyd 0 0 unknown}]",
+		} {
+			gotCharacters, err := c.SearchPeople(context.Background(), query)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var got []string
+			for _, ch := range gotCharacters {
+				got = append(got, fmt.Sprintf("{%s %v %v %s}", ch.Name, ch.Height, ch.Mass, ch.BirthYear))
+			}
+
+			if got := fmt.Sprint(got); got != want {
+				t.Errorf("got %s, want %s", got, want)
+			}
+		}
+	})
+
+	t.Run("Same results cold and warm", func(t *testing.T) {
+		ts, _ := fakeSearchSWAPI(t, resources, fields)
+
+		cold := NewClient(ts.URL)
+
+		warm := NewClient(ts.URL)
+
+		if _, err := warm.People(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		for _, resource := range []string{"starships", "films"} {
+			if _, err := warm.Resources(context.Background(), resource); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+
+		for _, tc := range []struct {
+			resource string
+			query    string
+			want     string
+		}{
+			{"people", "skywalker", "[Luke Skywalker Anakin Skywalker]"},
+			{"people", "Walker, Ana", "[Anakin Skywalker]"},
+			{"people", "3p", "[C-3PO]"},
+			{"people", "PADMÉ", "[Padmé Amidala]"},
+			{"people", "padme", "[]"},
+			{"people", "", "[Luke Skywalker C-3PO Anakin Skywalker Padmé Amidala Jabba Desilijic Tiure Arvel Crynyd]"},
+			{"people", "jabba", "[Jabba Desilijic Tiure]"},
+			{"people", "arvel", "[Arvel Crynyd]"},
+			{"people", "luke vader", "[]"},
+			{"starships", "falcon", "[Millennium Falcon]"},
+			{"starships", "freighter", "[Millennium Falcon]"},
+			{"starships", "t-65", "[X-wing]"},
+			{"films", "hope", "[A New Hope]"},
+			{"films", "the", "[The Empire Strikes Back]"},
+		} {
+			t.Run(tc.resource+"/"+tc.query, func(t *testing.T) {
+				coldEntities, err := cold.Search(context.Background(), tc.resource, tc.query)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				warmEntities, err := warm.Search(context.Background(), tc.resource, tc.query)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				if got, want := names(coldEntities), tc.want; got != want {
+					t.Errorf("got %s from SWAPI, want %s", got, want)
+				}
+
+				if got, want := fmt.Sprint(warmEntities), fmt.Sprint(coldEntities); got != want {
+					t.Errorf("got %s locally, want %s as from SWAPI", got, want)
+				}
+			})
+		}
+	})
+
+	t.Run("Non-OK response from API", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			},
+		))
+		defer ts.Close()
+
+		_, err := NewClient(ts.URL).Search(context.Background(), "people", "luke")
+
+		if err == nil {
+			t.Fatal("error is nil")
+		}
+
+		if got, want := err.Error(), "SWAPI returned HTTP 418"; got != want {
+			t.Errorf("err is %q, want %q", got, want)
+		}
+	})
+
+	t.Run("Unknown resource", func(t *testing.T) {
+		if _, err := NewClient("http://localhost:0").Search(context.Background(), "foo", "luke"); err == nil {
+			t.Error("error is nil")
+		}
+	})
+
+	t.Run("Offline", func(t *testing.T) {
+		c := NewClient("http://localhost:0", WithOfflineData([]starwars.Character{{Name: "C-3PO"}, {Name: "R2-D2"}}))
+
+		gotEntities, err := c.Search(context.Background(), "people", "r2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := names(gotEntities), "[R2-D2]"; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+
+		if _, err := c.Search(context.Background(), starwars.Planets, "tatooine"); err != ErrOffline {
+			t.Errorf("error is %v, want %v", err, ErrOffline)
+		}
+	})
+}
+
+func TestClient_Retries(t *testing.T) {
+	for _, tc := range []struct {
+		desc         string
+		failures     int
+		failCode     int
+		retries      int
+		wantReqCount int
+		wantErr      bool
+	}{
+		{"Success after retry", 2, http.StatusBadGateway, 2, 3, false},
+		{"Retries exhausted", 3, http.StatusBadGateway, 2, 3, true},
+		{"Too many requests", 1, http.StatusTooManyRequests, 1, 2, false},
+		{"Permanent error", 1, http.StatusNotFound, 2, 1, true},
+		{"No retries", 1, http.StatusBadGateway, 0, 1, true},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			var gotReqCount int
+
+			ts := httptest.NewServer(http.HandlerFunc(
+				func(w http.ResponseWriter, r *http.Request) {
+					gotReqCount++
+
+					if gotReqCount <= tc.failures {
+						w.WriteHeader(tc.failCode)
+						return
+					}
+
+					w.Write([]byte(`{"results": [{"name":"C-3PO"}]}`))
+				},
+			))
+
+			c := NewClient(ts.URL, WithRetries(tc.retries))
+
+			_, err := c.People(context.Background())
+
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Errorf("got error %v, want error: %t", err, tc.wantErr)
+			}
+
+			if got, want := gotReqCount, tc.wantReqCount; got != want {
+				t.Errorf("sent %d requests, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	done := make(chan struct{})
+	defer close(done)
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-done:
+			case <-r.Context().Done():
+			}
+		},
+	))
+	defer ts.Close()
+
+	c := NewClient(ts.URL, WithTimeout(10*time.Millisecond))
+
+	if _, err := c.People(context.Background()); err == nil {
+		t.Fatal("error is nil")
+	}
+}
+
+func TestClient_OfflineData(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			t.Errorf("unexpected request: %s", r.URL)
+		},
+	))
+
+	wantCharacters := []starwars.Character{{Name: "C-3PO"}}
+
+	c := NewClient(ts.URL, WithCacheTTL(time.Minute), WithOfflineData(wantCharacters))
+
+	c.cache.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+	if err := c.Refresh(context.Background()); err != nil {
+		t.Errorf("unexpected error refreshing: %v", err)
+	}
+
+	if err := c.Ping(context.Background()); err != nil {
+		t.Errorf("unexpected error pinging: %v", err)
+	}
+
+	gotCharacters, err := c.People(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if fmt.Sprint(gotCharacters) != fmt.Sprint(wantCharacters) {
+		t.Errorf("got %v, want %v", gotCharacters, wantCharacters)
+	}
+
+	if got, want := c.CacheStatus().Generation, uint64(1); got != want {
+		t.Errorf("cache generation is %d, want %d", got, want)
+	}
+}
+
+func TestClient_Tracing(t *testing.T) {
+	exporter := tracing.NewInMemoryExporter()
+
+	defer tracing.SetDefault(tracing.Default())
+	tracing.SetDefault(tracing.NewTracer(exporter))
+
+	var gotTraceparents []string
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			gotTraceparents = append(gotTraceparents, r.Header.Get("traceparent"))
+
+			if r.URL.Query().Get("page") == "2" {
+				w.Write([]byte(`{"results": [{"name":"R2-D2"}]}`))
+				return
+			}
+
+			fmt.Fprintf(w, `{"next": "http://%s/people/?page=2", "results": [{"name":"C-3PO"}]}`, r.Host)
+		},
+	))
+	defer ts.Close()
+
+	c := NewClient(ts.URL)
+
+	ctx, parent := tracing.Start(context.Background(), "parent", tracing.KindInternal)
+
+	if _, err := c.People(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parent.End()
+
+	spans := exporter.Spans()
+
+	if got, want := len(spans), 3; got != want {
+		t.Fatalf("got %d spans, want %d", got, want)
+	}
+
+	for n, span := range spans[:2] {
+		if got, want := span.Name, "GET people"; got != want {
+			t.Errorf("[%d] got name %q, want %q", n, got, want)
+		}
+
+		if got, want := span.Kind, tracing.KindClient; got != want {
+			t.Errorf("[%d] got kind %v, want %v", n, got, want)
+		}
+
+		if got, want := span.Parent, parent.SpanContext(); got != want {
+			t.Errorf("[%d] got parent %v, want %v", n, got, want)
+		}
+
+		if got, want := gotTraceparents[n], tracing.FormatTraceparent(span.SpanContext); got != want {
+			t.Errorf("[%d] got traceparent %q, want %q", n, got, want)
+		}
+	}
+}
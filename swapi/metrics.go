@@ -0,0 +1,50 @@
+package swapi
+
+import (
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/metrics"
+)
+
+type clientMetrics struct {
+	pageDuration *metrics.Histogram
+	pageErrors   *metrics.Counter
+	cacheHits    *metrics.Counter
+	cacheMisses  *metrics.Counter
+}
+
+func newClientMetrics(reg *metrics.Registry, c *Client) *clientMetrics {
+	reg.GaugeFunc(
+		"swapi_cache_age_seconds",
+		"Age of the cached SWAPI data in seconds.",
+		func() float64 { return c.CacheStatus().Age(time.Now()).Seconds() },
+	)
+
+	reg.GaugeFunc(
+		"swapi_cache_entries",
+		"Number of characters in the SWAPI cache.",
+		func() float64 { return float64(c.CacheStatus().Entries) },
+	)
+
+	return &clientMetrics{
+		pageDuration: reg.Histogram(
+			"swapi_page_request_duration_seconds",
+			"Duration of SWAPI page requests in seconds.",
+			metrics.DefaultBuckets,
+			"resource",
+		),
+		pageErrors: reg.Counter(
+			"swapi_page_errors_total",
+			"Total number of failed SWAPI page requests.",
+			"resource",
+		),
+		cacheHits: reg.Counter(
+			"swapi_cache_hits_total",
+			"Total number of lookups served from the SWAPI cache.",
+		),
+		cacheMisses: reg.Counter(
+			"swapi_cache_misses_total",
+			"Total number of lookups not served from the SWAPI cache.",
+		),
+	}
+}
@@ -0,0 +1,152 @@
+package swapi
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// ErrOffline is returned when requesting data that is not available when
+// serving offline data.
+var ErrOffline = fmt.Errorf("%w offline", starwars.ErrUnavailable)
+
+// resourceCache caches the entities of each kind, e.g. planets or films, for
+// the same time as characters.
+type resourceCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	now      func() time.Time
+	entities map[string][]starwars.Entity
+	updated  map[string]time.Time
+}
+
+func newResourceCache(ttl time.Duration) *resourceCache {
+	return &resourceCache{
+		ttl:      ttl,
+		now:      time.Now,
+		entities: make(map[string][]starwars.Entity),
+		updated:  make(map[string]time.Time),
+	}
+}
+
+func (c *resourceCache) Get(kind string) (es []starwars.Entity, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	es, ok = c.entities[kind]
+	if !ok || (c.ttl > 0 && c.now().Sub(c.updated[kind]) >= c.ttl) {
+		return nil, false
+	}
+	return slices.Clone(es), true
+}
+
+func (c *resourceCache) Set(kind string, es []starwars.Entity) {
+	c.mu.Lock()
+	c.entities[kind] = slices.Clone(es)
+	c.updated[kind] = c.now()
+	c.mu.Unlock()
+}
+
+func (c *resourceCache) Purge() {
+	c.mu.Lock()
+	clear(c.entities)
+	clear(c.updated)
+	c.mu.Unlock()
+}
+
+// Resources returns all entities of the given kind, one of starwars.Kinds.
+// They are fetched when first needed and cached like characters. Offline data
+// includes no entities, so ErrOffline is returned when serving it.
+func (c *Client) Resources(ctx context.Context, kind string) ([]starwars.Entity, error) {
+	if !slices.Contains(starwars.Kinds, kind) {
+		return nil, fmt.Errorf("unknown resource %q", kind)
+	}
+
+	if c.offline {
+		return nil, ErrOffline
+	}
+
+	if es, ok := c.resources.Get(kind); ok {
+		c.metrics.cacheHits.Inc()
+		return es, nil
+	}
+
+	c.metrics.cacheMisses.Inc()
+
+	es, err := c.fetchResources(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+
+	c.resources.Set(kind, es)
+
+	return es, nil
+}
+
+func (c *Client) fetchResources(ctx context.Context, kind string) ([]starwars.Entity, error) {
+	var entities []starwars.Entity
+
+	nextURL := c.baseURL + "/" + kind + "/"
+
+	for nextURL != "" {
+		var respBody struct {
+			Next    string           `json:"next"`
+			Results []map[string]any `json:"results"`
+		}
+
+		if err := c.getPage(ctx, kind, nextURL, &respBody); err != nil {
+			return nil, err
+		}
+
+		for _, result := range respBody.Results {
+			entities = append(entities, entity(result))
+		}
+
+		nextURL = respBody.Next
+	}
+
+	return entities, nil
+}
+
+// entity converts a SWAPI resource into an entity, keeping the name (or title)
+// and URL, and the attributes that are plain values. References to other
+// resources and record timestamps are left out.
+func entity(v map[string]any) starwars.Entity {
+	var e starwars.Entity
+
+	for key, value := range v {
+		s, ok := value.(string)
+		if !ok {
+			if f, isNumber := value.(float64); isNumber {
+				s, ok = fmt.Sprint(f), true
+			}
+		}
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "name", "title":
+			e.Name = s
+		case "url":
+			e.URL = s
+		case "created", "edited":
+		default:
+			if strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://") {
+				continue
+			}
+			e.Attributes = append(e.Attributes, starwars.Attribute{Key: key, Value: s})
+		}
+	}
+
+	sort.Slice(e.Attributes, func(i, j int) bool {
+		return e.Attributes[i].Key < e.Attributes[j].Key
+	})
+
+	return e
+}
@@ -0,0 +1,208 @@
+package swapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/jsageryd/starwars-coding-test/starwars"
+)
+
+// people is the SWAPI resource of characters.
+const people = "people"
+
+// searchFields are the fields that SWAPI searches, by resource.
+var searchFields = map[string][]string{
+	people:             {"name"},
+	starwars.Films:     {"title"},
+	starwars.Planets:   {"name"},
+	starwars.Species:   {"name"},
+	starwars.Starships: {"name", "model"},
+	starwars.Vehicles:  {"name", "model"},
+}
+
+// Search returns the entities of the given resource, "people" or one of
+// starwars.Kinds, that match query the way SWAPI searches do: every word of
+// the query must occur, regardless of case, in one of the searched fields,
+// which are the name (or title) and for starships and vehicles also the model.
+// Results are in SWAPI order. If the resource is cached it is searched
+// locally, and otherwise by SWAPI, without fetching all of the resource.
+func (c *Client) Search(ctx context.Context, resource, query string) ([]starwars.Entity, error) {
+	fields, ok := searchFields[resource]
+	if !ok {
+		return nil, fmt.Errorf("unknown resource %q", resource)
+	}
+
+	if resource == people {
+		cs, err := c.SearchPeople(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		es := make([]starwars.Entity, len(cs))
+		for i, ch := range cs {
+			es[i] = characterEntity(ch)
+		}
+
+		return es, nil
+	}
+
+	if es, ok := c.resources.Get(resource); ok {
+		c.metrics.cacheHits.Inc()
+		return searchLocally(es, fields, query), nil
+	}
+
+	if c.offline {
+		return nil, ErrOffline
+	}
+
+	c.metrics.cacheMisses.Inc()
+
+	results, err := c.fetchSearch(ctx, resource, query)
+	if err != nil {
+		return nil, err
+	}
+
+	es := make([]starwars.Entity, len(results))
+
+	for i, result := range results {
+		var v map[string]any
+		if err := json.Unmarshal(result, &v); err != nil {
+			return nil, fmt.Errorf("error reading SWAPI response: %v", err)
+		}
+
+		es[i] = entity(v)
+	}
+
+	return es, nil
+}
+
+// SearchPeople returns the characters whose names match query, as Search
+// does for the people resource.
+func (c *Client) SearchPeople(ctx context.Context, query string) ([]starwars.Character, error) {
+	if cs, ok := c.cache.GetCharacters(); ok {
+		c.metrics.cacheHits.Inc()
+
+		terms := searchTerms(query)
+
+		var matches []starwars.Character
+
+		for _, ch := range cs {
+			if matchesTerms(characterEntity(ch), searchFields[people], terms) {
+				matches = append(matches, ch)
+			}
+		}
+
+		return matches, nil
+	}
+
+	if c.offline {
+		return nil, ErrOffline
+	}
+
+	c.metrics.cacheMisses.Inc()
+
+	results, err := c.fetchSearch(ctx, people, query)
+	if err != nil {
+		return nil, err
+	}
+
+	cs := make([]starwars.Character, len(results))
+
+	for i, result := range results {
+		if err := json.Unmarshal(result, &cs[i]); err != nil {
+			return nil, fmt.Errorf("error reading SWAPI response: %v", err)
+		}
+	}
+
+	return cs, nil
+}
+
+// fetchSearch returns the results of searching the given resource by SWAPI.
+func (c *Client) fetchSearch(ctx context.Context, resource, query string) ([]json.RawMessage, error) {
+	var results []json.RawMessage
+
+	nextURL := c.baseURL + "/" + resource + "/?search=" + url.QueryEscape(query)
+
+	for nextURL != "" {
+		var respBody struct {
+			Next    string            `json:"next"`
+			Results []json.RawMessage `json:"results"`
+		}
+
+		if err := c.getPage(ctx, resource, nextURL, &respBody); err != nil {
+			return nil, err
+		}
+
+		results = append(results, respBody.Results...)
+
+		nextURL = respBody.Next
+	}
+
+	return results, nil
+}
+
+// characterEntity converts a character into an entity.
+func characterEntity(ch starwars.Character) starwars.Entity {
+	b, _ := json.Marshal(ch)
+
+	var v map[string]any
+	json.Unmarshal(b, &v)
+
+	return entity(v)
+}
+
+// searchLocally returns the entities matching query in the given fields, as SWAPI
+// matches them.
+func searchLocally(es []starwars.Entity, fields []string, query string) []starwars.Entity {
+	terms := searchTerms(query)
+
+	var matches []starwars.Entity
+
+	for _, e := range es {
+		if matchesTerms(e, fields, terms) {
+			matches = append(matches, e)
+		}
+	}
+
+	return matches
+}
+
+// searchTerms returns the words of query, in lower case, as SWAPI splits them.
+func searchTerms(query string) []string {
+	return strings.Fields(strings.ToLower(strings.ReplaceAll(query, ",", " ")))
+}
+
+// matchesTerms reports whether every term, in lower case, occurs in one of the
+// given fields of e.
+func matchesTerms(e starwars.Entity, fields []string, terms []string) bool {
+	for _, term := range terms {
+		var found bool
+
+		for _, field := range fields {
+			value := e.Name
+
+			if field != "name" && field != "title" {
+				value = ""
+				for _, attr := range e.Attributes {
+					if attr.Key == field {
+						value = attr.Value
+					}
+				}
+			}
+
+			if strings.Contains(strings.ToLower(value), term) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
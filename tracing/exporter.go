@@ -0,0 +1,302 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InMemoryExporter keeps exported spans in memory, for tests.
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(s SpanData) {
+	e.mu.Lock()
+	e.spans = append(e.spans, s)
+	e.mu.Unlock()
+}
+
+func (e *InMemoryExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// Spans returns the spans exported so far, in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SpanData(nil), e.spans...)
+}
+
+// JSONExporter writes each span as a line of JSON.
+type JSONExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONExporter returns an exporter writing to w, which is typically
+// os.Stderr, so that spans are not mixed with the output of commands.
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{w: w}
+}
+
+func (e *JSONExporter) ExportSpan(s SpanData) {
+	b, _ := json.Marshal(otlpSpanFrom(s))
+
+	e.mu.Lock()
+	e.w.Write(append(b, '\n'))
+	e.mu.Unlock()
+}
+
+func (e *JSONExporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+// OTLPExporter sends spans to an OpenTelemetry collector using OTLP over HTTP
+// with JSON encoding. Spans are sent in batches, when the batch is full or at
+// the flush interval, whichever comes first.
+type OTLPExporter struct {
+	endpoint    string
+	serviceName string
+	client      *http.Client
+
+	mu      sync.Mutex
+	batch   []SpanData
+	flushc  chan struct{}
+	stopc   chan struct{}
+	stopped chan struct{}
+}
+
+const (
+	otlpBatchSize     = 512
+	otlpFlushInterval = 5 * time.Second
+)
+
+// NewOTLPExporter returns an exporter sending spans to the collector at
+// endpoint, e.g. "http://localhost:4318", attributed to the named service.
+func NewOTLPExporter(endpoint, serviceName string) *OTLPExporter {
+	e := &OTLPExporter{
+		endpoint:    strings.TrimRight(endpoint, "/") + "/v1/traces",
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		flushc:      make(chan struct{}, 1),
+		stopc:       make(chan struct{}),
+		stopped:     make(chan struct{}),
+	}
+
+	go e.run()
+
+	return e
+}
+
+func (e *OTLPExporter) ExportSpan(s SpanData) {
+	e.mu.Lock()
+	e.batch = append(e.batch, s)
+	full := len(e.batch) >= otlpBatchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.flushc <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Shutdown sends any remaining spans and stops the exporter.
+func (e *OTLPExporter) Shutdown(ctx context.Context) error {
+	close(e.stopc)
+
+	select {
+	case <-e.stopped:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return e.flush(ctx)
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.stopc:
+			return
+		case <-ticker.C:
+		case <-e.flushc:
+		}
+
+		if err := e.flush(context.Background()); err != nil {
+			slog.Error("Error exporting spans", "error", err)
+		}
+	}
+}
+
+func (e *OTLPExporter) flush(ctx context.Context) error {
+	e.mu.Lock()
+	batch := e.batch
+	e.batch = nil
+	e.mu.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	spans := make([]otlpSpan, len(batch))
+	for i, s := range batch {
+		spans[i] = otlpSpanFrom(s)
+	}
+
+	body, err := json.Marshal(otlpRequest{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{otlpAttributeFrom(Attr("service.name", e.serviceName))},
+			},
+			ScopeSpans: []otlpScopeSpans{{
+				Scope: otlpScope{Name: e.serviceName},
+				Spans: spans,
+			}},
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding spans: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating OTLP request: %v", err)
+	}
+
+	req.Header.Set("content-type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending spans: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("OTLP endpoint returned HTTP %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// The types below follow the JSON encoding of the OTLP trace protocol.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 2 is error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otlpSpanFrom(s SpanData) otlpSpan {
+	span := otlpSpan{
+		TraceID:           s.SpanContext.TraceID.String(),
+		SpanID:            s.SpanContext.SpanID.String(),
+		Name:              s.Name,
+		Kind:              int(s.Kind),
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+	}
+
+	if s.Parent.IsValid() {
+		span.ParentSpanID = s.Parent.SpanID.String()
+	}
+
+	for _, attr := range s.Attributes {
+		span.Attributes = append(span.Attributes, otlpAttributeFrom(attr))
+	}
+
+	if s.Err != "" {
+		span.Status = &otlpStatus{Code: 2, Message: s.Err}
+	}
+
+	return span
+}
+
+func otlpAttributeFrom(attr Attribute) otlpAttribute {
+	var v otlpValue
+
+	switch value := attr.Value.(type) {
+	case string:
+		v.StringValue = &value
+	case bool:
+		v.BoolValue = &value
+	case int:
+		s := strconv.Itoa(value)
+		v.IntValue = &s
+	case int64:
+		s := strconv.FormatInt(value, 10)
+		v.IntValue = &s
+	case float64:
+		v.DoubleValue = &value
+	default:
+		s := fmt.Sprint(value)
+		v.StringValue = &s
+	}
+
+	return otlpAttribute{Key: attr.Key, Value: v}
+}
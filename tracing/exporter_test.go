@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestJSONExporter(t *testing.T) {
+	var buf strings.Builder
+
+	_, span := NewTracer(NewJSONExporter(&buf)).Start(context.Background(), "foo", KindClient, Attr("n", 1))
+	span.End()
+
+	var got otlpSpan
+
+	if err := json.Unmarshal([]byte(buf.String()), &got); err != nil {
+		t.Fatalf("error decoding output %q: %v", buf.String(), err)
+	}
+
+	if got, want := got.Name, "foo"; got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+
+	if got, want := got.Kind, int(KindClient); got != want {
+		t.Errorf("got kind %d, want %d", got, want)
+	}
+
+	if got, want := *got.Attributes[0].Value.IntValue, "1"; got != want {
+		t.Errorf("got attribute value %q, want %q", got, want)
+	}
+}
+
+func TestOTLPExporter(t *testing.T) {
+	reqs := make(chan otlpRequest, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if got, want := r.URL.Path, "/v1/traces"; got != want {
+				t.Errorf("got path %q, want %q", got, want)
+			}
+
+			var req otlpRequest
+
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				t.Errorf("error decoding request: %v", err)
+			}
+
+			reqs <- req
+		},
+	))
+
+	exporter := NewOTLPExporter(ts.URL, "foo-service")
+
+	tracer := NewTracer(exporter)
+
+	ctx, parent := tracer.Start(context.Background(), "parent", KindServer)
+	_, child := tracer.Start(ctx, "child", KindInternal, Attr("ok", true))
+	child.RecordError(errors.New("foo error"))
+	child.End()
+	parent.End()
+
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-reqs
+
+	rs := req.ResourceSpans[0]
+
+	if got, want := *rs.Resource.Attributes[0].Value.StringValue, "foo-service"; got != want {
+		t.Errorf("got service name %q, want %q", got, want)
+	}
+
+	spans := rs.ScopeSpans[0].Spans
+
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("got %d spans, want %d", got, want)
+	}
+
+	if got, want := spans[0].ParentSpanID, spans[1].SpanID; got != want {
+		t.Errorf("child has parent span ID %q, want %q", got, want)
+	}
+
+	if spans[0].Status == nil || spans[0].Status.Code != 2 || spans[0].Status.Message != "foo error" {
+		t.Errorf("child has status %+v, want error", spans[0].Status)
+	}
+
+	if got, want := len(spans[1].TraceID), 32; got != want {
+		t.Errorf("got trace ID of length %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,266 @@
+// Package tracing records OpenTelemetry-style spans, propagates trace context
+// using W3C traceparent headers and exports finished spans.
+//
+// Like log/slog, the package has a default tracer used by the package-level
+// functions. It records nothing until replaced using SetDefault.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id TraceID) IsValid() bool { return id != TraceID{} }
+
+type SpanID [8]byte
+
+func (id SpanID) String() string { return hex.EncodeToString(id[:]) }
+
+func (id SpanID) IsValid() bool { return id != SpanID{} }
+
+// SpanContext identifies a span within a trace.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Remote  bool // whether the span context was propagated from another process
+}
+
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+type Kind int
+
+const (
+	KindInternal Kind = iota + 1
+	KindServer
+	KindClient
+)
+
+// Attribute is a key-value pair describing a span. Values are strings, bools,
+// ints, int64s or float64s.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr returns an attribute with the given key and value.
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanData is a finished span as handed to exporters.
+type SpanData struct {
+	SpanContext SpanContext
+	Parent      SpanContext // zero if the span is a root span
+	Name        string
+	Kind        Kind
+	Start       time.Time
+	End         time.Time
+	Attributes  []Attribute
+	Err         string // error recorded on the span, empty if none
+}
+
+// Exporter receives finished spans.
+type Exporter interface {
+	ExportSpan(s SpanData)
+	Shutdown(ctx context.Context) error
+}
+
+// Tracer creates spans and hands them to its exporter when they end.
+type Tracer struct {
+	exporter Exporter
+}
+
+// NewTracer returns a tracer exporting spans to e. If e is nil, spans are
+// created and propagated but not exported.
+func NewTracer(e Exporter) *Tracer {
+	return &Tracer{exporter: e}
+}
+
+// Shutdown flushes and stops the exporter of the tracer.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// Start starts a span with the given name as a child of the span in ctx, if
+// any, and returns a copy of ctx carrying the new span. The span must be ended
+// by calling End.
+func (t *Tracer) Start(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	parent := SpanContextFromContext(ctx)
+
+	s := &Span{
+		tracer: t,
+		data: SpanData{
+			Parent:     parent,
+			Name:       name,
+			Kind:       kind,
+			Start:      time.Now(),
+			Attributes: attrs,
+		},
+	}
+
+	if parent.IsValid() {
+		s.data.SpanContext.TraceID = parent.TraceID
+	} else {
+		rand.Read(s.data.SpanContext.TraceID[:])
+	}
+	rand.Read(s.data.SpanContext.SpanID[:])
+
+	return context.WithValue(ctx, spanContextKey{}, s.data.SpanContext), s
+}
+
+// Span is an operation within a trace.
+type Span struct {
+	tracer *Tracer
+	mu     sync.Mutex
+	data   SpanData
+	ended  bool
+}
+
+// SpanContext returns the span context identifying s.
+func (s *Span) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+// SetAttributes adds attributes to s.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	s.mu.Lock()
+	s.data.Attributes = append(s.data.Attributes, attrs...)
+	s.mu.Unlock()
+}
+
+// RecordError marks s as failed with err, if err is not nil.
+func (s *Span) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.data.Err = err.Error()
+	s.mu.Unlock()
+}
+
+// End ends s and exports it. Calls after the first have no effect.
+func (s *Span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+	data := s.data
+	s.mu.Unlock()
+
+	if s.tracer.exporter != nil {
+		s.tracer.exporter.ExportSpan(data)
+	}
+}
+
+type spanContextKey struct{}
+
+// SpanContextFromContext returns the span context carried by ctx, or the zero
+// span context if there is none.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx carrying sc, as extracted
+// from an incoming request.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// Extract returns a copy of ctx carrying the span context in the traceparent
+// header of h, if there is a valid one.
+func Extract(ctx context.Context, h http.Header) context.Context {
+	sc, err := ParseTraceparent(h.Get("traceparent"))
+	if err != nil {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, sc)
+}
+
+// Inject sets the traceparent header of h from the span context in ctx, if
+// there is one.
+func Inject(ctx context.Context, h http.Header) {
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		h.Set("traceparent", FormatTraceparent(sc))
+	}
+}
+
+// FormatTraceparent returns sc as a W3C traceparent header value. Spans are
+// always sampled.
+func FormatTraceparent(sc SpanContext) string {
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-01"
+}
+
+// ParseTraceparent parses a W3C traceparent header value.
+func ParseTraceparent(s string) (SpanContext, error) {
+	var sc SpanContext
+
+	// version-traceid-parentid-flags
+	if len(s) < 55 || s[2] != '-' || s[35] != '-' || s[52] != '-' {
+		return sc, fmt.Errorf("malformed traceparent %q", s)
+	}
+
+	version, err := hex.DecodeString(s[:2])
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(s) != 55) {
+		return sc, fmt.Errorf("malformed traceparent %q", s)
+	}
+
+	if _, err := hex.Decode(sc.TraceID[:], []byte(s[3:35])); err != nil {
+		return sc, fmt.Errorf("malformed traceparent %q", s)
+	}
+
+	if _, err := hex.Decode(sc.SpanID[:], []byte(s[36:52])); err != nil {
+		return sc, fmt.Errorf("malformed traceparent %q", s)
+	}
+
+	if _, err := hex.DecodeString(s[53:55]); err != nil {
+		return sc, fmt.Errorf("malformed traceparent %q", s)
+	}
+
+	if !sc.IsValid() {
+		return sc, fmt.Errorf("invalid trace or span ID in traceparent %q", s)
+	}
+
+	return sc, nil
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+func init() {
+	defaultTracer.Store(NewTracer(nil))
+}
+
+// Default returns the default tracer.
+func Default() *Tracer {
+	return defaultTracer.Load()
+}
+
+// SetDefault makes t the default tracer.
+func SetDefault(t *Tracer) {
+	defaultTracer.Store(t)
+}
+
+// Start starts a span using the default tracer.
+func Start(ctx context.Context, name string, kind Kind, attrs ...Attribute) (context.Context, *Span) {
+	return Default().Start(ctx, name, kind, attrs...)
+}
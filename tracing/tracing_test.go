@@ -0,0 +1,158 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestTracer_Start(t *testing.T) {
+	exporter := NewInMemoryExporter()
+
+	tracer := NewTracer(exporter)
+
+	ctx, parent := tracer.Start(context.Background(), "parent", KindServer, Attr("foo", "bar"))
+
+	_, child := tracer.Start(ctx, "child", KindInternal)
+	child.RecordError(errors.New("foo error"))
+	child.End()
+	child.End()
+
+	parent.SetAttributes(Attr("baz", 1))
+	parent.End()
+
+	spans := exporter.Spans()
+
+	if got, want := len(spans), 2; got != want {
+		t.Fatalf("got %d spans, want %d", got, want)
+	}
+
+	gotChild, gotParent := spans[0], spans[1]
+
+	if got, want := gotParent.Name, "parent"; got != want {
+		t.Errorf("got name %q, want %q", got, want)
+	}
+
+	if gotParent.Parent.IsValid() {
+		t.Errorf("root span has parent %v", gotParent.Parent)
+	}
+
+	if got, want := len(gotParent.Attributes), 2; got != want {
+		t.Errorf("got %d attributes, want %d", got, want)
+	}
+
+	if got, want := gotChild.SpanContext.TraceID, gotParent.SpanContext.TraceID; got != want {
+		t.Errorf("child has trace ID %s, want %s", got, want)
+	}
+
+	if got, want := gotChild.Parent.SpanID, gotParent.SpanContext.SpanID; got != want {
+		t.Errorf("child has parent span ID %s, want %s", got, want)
+	}
+
+	if got, want := gotChild.Err, "foo error"; got != want {
+		t.Errorf("child has error %q, want %q", got, want)
+	}
+
+	if gotChild.End.Before(gotChild.Start) {
+		t.Errorf("child ended at %v, before it started at %v", gotChild.End, gotChild.Start)
+	}
+}
+
+func TestTraceparent(t *testing.T) {
+	t.Run("Round trip", func(t *testing.T) {
+		const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		sc, err := ParseTraceparent(traceparent)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+			t.Errorf("got trace ID %s, want %s", got, want)
+		}
+
+		if got, want := sc.SpanID.String(), "00f067aa0ba902b7"; got != want {
+			t.Errorf("got span ID %s, want %s", got, want)
+		}
+
+		if got, want := FormatTraceparent(sc), traceparent; got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for n, input := range []string{
+			"",
+			"foo",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e473x-00f067aa0ba902b7-01",
+		} {
+			if _, err := ParseTraceparent(input); err == nil {
+				t.Errorf("[%d] ParseTraceparent(%q): error is nil", n, input)
+			}
+		}
+	})
+
+	t.Run("Extract and inject", func(t *testing.T) {
+		const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+		in := http.Header{}
+		in.Set("traceparent", traceparent)
+
+		exporter := NewInMemoryExporter()
+
+		ctx, span := NewTracer(exporter).Start(Extract(context.Background(), in), "foo", KindServer)
+
+		out := http.Header{}
+
+		Inject(ctx, out)
+
+		span.End()
+
+		sc, err := ParseTraceparent(out.Get("traceparent"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if got, want := sc.TraceID.String(), "4bf92f3577b34da6a3ce929d0e0e4736"; got != want {
+			t.Errorf("got trace ID %s, want %s", got, want)
+		}
+
+		if got, want := sc.SpanID, span.SpanContext().SpanID; got != want {
+			t.Errorf("got span ID %s, want %s", got, want)
+		}
+
+		if got := exporter.Spans()[0].Parent; !got.Remote {
+			t.Errorf("parent %+v is not remote", got)
+		}
+	})
+
+	t.Run("Inject without span", func(t *testing.T) {
+		h := http.Header{}
+
+		Inject(context.Background(), h)
+
+		if got := h.Get("traceparent"); got != "" {
+			t.Errorf("got traceparent %q, want none", got)
+		}
+	})
+}
+
+func TestDefault(t *testing.T) {
+	defer SetDefault(Default())
+
+	exporter := NewInMemoryExporter()
+
+	SetDefault(NewTracer(exporter))
+
+	_, span := Start(context.Background(), "foo", KindInternal)
+	span.End()
+
+	if got, want := len(exporter.Spans()), 1; got != want {
+		t.Errorf("got %d spans, want %d", got, want)
+	}
+}